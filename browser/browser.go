@@ -1,13 +1,19 @@
+// Package browser implements the server browser protocol of Teeworlds 0.7.
+// It is the protocol that uses the token handshake (see FetchToken) before any
+// data is requested from the masterservers or gameservers.
 package browser
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net"
+	"strings"
 	"time"
 
 	"github.com/jxsl13/twapi/compression"
@@ -38,6 +44,17 @@ const (
 	maxServersPerMasterServer = 75
 
 	minTimeout = 60 * time.Millisecond
+
+	minWriteBurst = 1         // number of requests that are sent in the first round
+	maxWriteBurst = maxChunks // max number of requests that are sent in one round
+
+	serverFlagPassword = 1 // server flag that is set if the server requires a password
+
+	pingProbes = 3 // number of round trips that are measured by PingServer
+
+	minTokenValidity = time.Second // tokens that expire sooner are not reused by the TokenCache
+
+	drainTimeout = time.Millisecond // time that the Querier waits for queued responses before every query
 )
 
 var (
@@ -54,7 +71,7 @@ var (
 	ErrInvalidIP = errors.New("invalid IP error, passed")
 
 	// ErrInvalidPort is returned if the passed port is either negative or an invalid value above 65536.
-	ErrInvalidPort = errors.New("invalid IP error, passed")
+	ErrInvalidPort = errors.New("invalid port error, passed")
 
 	// ErrTokenExpired is returned when a request packet is being constructed with an expired token
 	ErrTokenExpired = errors.New("token expired")
@@ -84,6 +101,21 @@ var (
 	// ErrRequestResponseMismatch is returned by functions that request and receive data, but the received data does not match the requested data.
 	ErrRequestResponseMismatch = errors.New("request response mismatch")
 
+	// ErrUnknownPacketType is returned if an unknown packet type is passed to Request or one of the Fetch functions.
+	ErrUnknownPacketType = errors.New("unknown packet type")
+
+	// ErrTokenMismatch is returned if a received response does not contain the token of the request,
+	// e.g. a late response to a previous request or a spoofed response.
+	ErrTokenMismatch = errors.New("token mismatch")
+
+	// ErrValueTooLong is returned by ServerInfo.WriteTo if a string or the player list is too long
+	// to be read by ReadServerInfo.
+	ErrValueTooLong = errors.New("value too long")
+
+	// ErrValueOutOfRange is returned by ServerInfo.WriteTo if a numeric value cannot be packed,
+	// because it is outside of the 32 bit range of compression.VarInt.
+	ErrValueOutOfRange = errors.New("value out of range")
+
 	// TokenExpirationDuration sets the protocol expiration time of a token
 	// This variable can be changed
 	TokenExpirationDuration = time.Second * 16
@@ -113,26 +145,89 @@ func init() {
 		log.Println("Initializing twapi package...")
 	}
 
-	MasterServerAddresses = make([]*net.UDPAddr, 0, len(masterServerHostnameAddresses))
+	addrs, err := ResolveMasterServers(context.Background())
+	if err != nil && Logging {
+		log.Println(err)
+	}
+	MasterServerAddresses = addrs
 
-	for _, ms := range masterServerHostnameAddresses {
-		srv, err := net.ResolveUDPAddr("udp", ms)
-		if err != nil {
-			if Logging {
-				log.Printf("Failed to resolve: %s\n", ms)
-			}
-		} else {
-			if Logging {
-				log.Printf("Resolved masterserver: %s -> %s\n", ms, srv.String())
-			}
-			MasterServerAddresses = append(MasterServerAddresses, srv)
+	if Logging {
+		for _, srv := range MasterServerAddresses {
+			log.Printf("Resolved masterserver: %s\n", srv.String())
 		}
 	}
+
 	if Logging && len(MasterServerAddresses) == 0 {
 		log.Println("Could not resolve any masterservers.... please check your internet connection.")
 	}
 }
 
+// ResolveError is returned if some of the addresses could not be resolved.
+type ResolveError struct {
+	// Errors contains one error per address that could not be resolved
+	Errors []error
+}
+
+func (e *ResolveError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("failed to resolve %d addresses: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// ResolveMasterServers resolves the hostnames of the official masterservers at call time,
+// as their IPs may change over time.
+// If some of the masterservers cannot be resolved, the successfully resolved addresses are returned
+// together with a *ResolveError that contains the errors of the other masterservers.
+func ResolveMasterServers(ctx context.Context) ([]*net.UDPAddr, error) {
+	return resolveUDPAddrs(ctx, masterServerHostnameAddresses)
+}
+
+// resolveUDPAddrs resolves the passed host:port addresses, IPv4 addresses are preferred.
+func resolveUDPAddrs(ctx context.Context, hostPorts []string) ([]*net.UDPAddr, error) {
+	addrs := make([]*net.UDPAddr, 0, len(hostPorts))
+	errs := make([]error, 0)
+
+	for _, hostPort := range hostPorts {
+		addr, err := resolveUDPAddr(ctx, hostPort)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to resolve %s: %w", hostPort, err))
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+
+	if len(errs) > 0 {
+		return addrs, &ResolveError{errs}
+	}
+	return addrs, nil
+}
+
+func resolveUDPAddr(ctx context.Context, hostPort string) (*net.UDPAddr, error) {
+	host, portStr, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := net.DefaultResolver.LookupPort(ctx, "udp", portStr)
+	if err != nil {
+		return nil, err
+	}
+
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ip := range ips {
+		if ip.IP.To4() != nil {
+			return &net.UDPAddr{IP: ip.IP, Port: port, Zone: ip.Zone}, nil
+		}
+	}
+	return &net.UDPAddr{IP: ips[0].IP, Port: port, Zone: ips[0].Zone}, nil
+}
+
 // ReadWriteDeadliner narrows the used uparations of the passed type.
 // in order to have a wider range of types that can satisfy this interface.
 type ReadWriteDeadliner interface {
@@ -199,6 +294,7 @@ type ServerInfo struct {
 	Map         string       `json:"map"`
 	GameType    string       `json:"gametype"`
 	ServerFlags int          `json:"server_flags"`
+	Passworded  bool         `json:"passworded"`
 	SkillLevel  int          `json:"skill_level"`
 	NumPlayers  int          `json:"num_players"`
 	MaxPlayers  int          `json:"max_players"`
@@ -216,6 +312,7 @@ func (s *ServerInfo) Empty() bool {
 		s.Map == "" &&
 		s.GameType == "" &&
 		s.ServerFlags == 0 &&
+		!s.Passworded &&
 		s.SkillLevel == 0 &&
 		s.NumPlayers == 0 &&
 		s.MaxPlayers == 0 &&
@@ -224,6 +321,22 @@ func (s *ServerInfo) Empty() bool {
 		len(s.Players) == 0
 }
 
+// Key returns the canonical ip:port address of the server, which identifies a server.
+// Two infos with the same key are considered to be infos of the same server, e.g. ConcurrentMap uses the key
+// in order to keep only a single info per server.
+// If the address cannot be parsed, it is returned as is.
+func (s *ServerInfo) Key() string {
+	host, port, err := net.SplitHostPort(s.Address)
+	if err != nil {
+		return s.Address
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		host = ip.String() // e.g. IPv4-mapped IPv6 addresses are converted to IPv4
+	}
+	return net.JoinHostPort(host, port)
+}
+
 // fix synchronizes the length of playerInfo with its struct field
 func (s *ServerInfo) fix() {
 	s.NumClients = len(s.Players)
@@ -233,7 +346,7 @@ func (s *ServerInfo) fix() {
 func (s *ServerInfo) Equal(other ServerInfo) bool {
 	s.fix()
 	other.fix()
-	equalData := s.Address == other.Address && s.Version == other.Version && s.Name == other.Name && s.Hostname == other.Hostname && s.Map == other.Map && s.GameType == other.GameType && s.ServerFlags == other.ServerFlags && s.SkillLevel == other.SkillLevel && s.NumPlayers == other.NumPlayers && s.MaxPlayers == other.MaxPlayers && s.NumClients == other.NumClients && s.MaxClients == other.MaxClients
+	equalData := s.Address == other.Address && s.Version == other.Version && s.Name == other.Name && s.Hostname == other.Hostname && s.Map == other.Map && s.GameType == other.GameType && s.ServerFlags == other.ServerFlags && s.Passworded == other.Passworded && s.SkillLevel == other.SkillLevel && s.NumPlayers == other.NumPlayers && s.MaxPlayers == other.MaxPlayers && s.NumClients == other.NumClients && s.MaxClients == other.MaxClients
 
 	// equal Players
 	if len(s.Players) != len(other.Players) {
@@ -274,7 +387,11 @@ func (s *ServerInfo) MarshalBinary() (data []byte, err error) {
 	data = append(data, []byte(s.GameType)...)
 	data = append(data, delimiter...)
 
-	data = append(data, byte(s.ServerFlags), byte(s.SkillLevel))
+	flags := s.ServerFlags
+	if s.Passworded {
+		flags |= serverFlagPassword
+	}
+	data = append(data, byte(flags), byte(s.SkillLevel))
 
 	var v compression.VarInt
 
@@ -296,10 +413,18 @@ func (s *ServerInfo) MarshalBinary() (data []byte, err error) {
 
 // UnmarshalBinary creates a serverinfo from binary data
 func (s *ServerInfo) UnmarshalBinary(data []byte) (err error) {
+	_, err = s.unmarshal(data)
+	return
+}
 
+// unmarshal is the same as UnmarshalBinary, but it additionally returns the offset in data
+// of the field that could not be unmarshaled, or the number of unmarshaled bytes if there was no error.
+func (s *ServerInfo) unmarshal(data []byte) (offset int, err error) {
 	slots := bytes.SplitN(data, delimiter, 6) // create 6 slots
 	if len(slots) != 6 {
-		return fmt.Errorf("%w : expected slots: 6 got: %d", ErrMalformedResponseData, len(slots))
+		// the last slot is the field that is not terminated
+		offset = len(data) - len(slots[len(slots)-1])
+		return offset, fmt.Errorf("%w : expected slots: 6 got: %d", ErrMalformedResponseData, len(slots))
 	}
 
 	s.Version = string(slots[0])
@@ -308,63 +433,64 @@ func (s *ServerInfo) UnmarshalBinary(data []byte) (err error) {
 	s.Map = string(slots[3])
 	s.GameType = string(slots[4])
 
-	data = slots[5] // get next raw data chunk
+	rest := slots[5] // get next raw data chunk
+	offset = len(data) - len(rest)
+	if len(rest) < 2 {
+		return offset, fmt.Errorf("%w : expected server flags and skill level, got %d bytes", ErrMalformedResponseData, len(rest))
+	}
 
-	s.ServerFlags = int(data[0])
-	s.SkillLevel = int(data[1])
+	s.ServerFlags = int(rest[0])
+	s.Passworded = s.ServerFlags&serverFlagPassword != 0
+	s.SkillLevel = int(rest[1])
 
-	data = data[2:] // skip first two already evaluated bytes
-	v := compression.NewVarIntFrom(data)
-	s.NumPlayers, err = v.Unpack()
-	if err != nil {
-		return
-	}
-	s.MaxPlayers, err = v.Unpack()
-	if err != nil {
-		return
+	v := compression.NewVarIntFrom(rest[2:]) // skip first two already evaluated bytes
+	for _, value := range []*int{&s.NumPlayers, &s.MaxPlayers, &s.NumClients} {
+		offset = len(data) - v.Size()
+		if *value, err = v.Unpack(); err != nil {
+			return
+		}
 	}
-	s.NumClients, err = v.Unpack()
-	if err != nil {
-		return
+
+	if s.NumClients < 0 {
+		return offset, fmt.Errorf("%w : invalid number of clients: %d", ErrMalformedResponseData, s.NumClients)
 	}
-	s.MaxClients, err = v.Unpack()
-	if err != nil {
+
+	offset = len(data) - v.Size()
+	if s.MaxClients, err = v.Unpack(); err != nil {
 		return
 	}
 
-	// preallocate space for player pointers
-	s.Players = make([]PlayerInfo, 0, s.NumClients)
-
-	v.Bytes() // return the not yet used remaining data
+	// preallocate space for the players, every player needs at least one byte
+	size := s.NumClients
+	if v.Size() < size {
+		size = v.Size()
+	}
+	s.Players = make([]PlayerInfo, 0, size)
 
 	for i := 0; i < s.NumClients; i++ {
 		player := PlayerInfo{}
 
+		offset = len(data) - v.Size()
 		slots := bytes.SplitN(v.Bytes(), delimiter, 3) // create 3 slots
 		if len(slots) != 3 {
-			return fmt.Errorf("%w : expected slots: 3 got: %d", ErrMalformedResponseData, len(slots))
+			offset = len(data) - len(slots[len(slots)-1])
+			return offset, fmt.Errorf("%w : expected slots: 3 got: %d", ErrMalformedResponseData, len(slots))
 		}
 
 		player.Name = string(slots[0])
 		player.Clan = string(slots[1])
 
 		v = compression.NewVarIntFrom(slots[2])
-		player.Country, err = v.Unpack()
-		if err != nil {
-			return
-		}
-		player.Score, err = v.Unpack()
-		if err != nil {
-			return
-		}
-		player.Type, err = v.Unpack()
-		if err != nil {
-			return
+		for _, value := range []*int{&player.Country, &player.Score, &player.Type} {
+			offset = len(data) - v.Size()
+			if *value, err = v.Unpack(); err != nil {
+				return
+			}
 		}
 
 		s.Players = append(s.Players, player)
 	}
-	return
+	return len(data) - v.Size(), nil
 }
 
 // PlayerInfo contains a players externally visible information
@@ -408,6 +534,197 @@ func (p *PlayerInfo) marshalBinary() (data []byte, err error) {
 	return
 }
 
+// WriteTo writes a dense binary representation of the server info to w,
+// which can be read with ReadServerInfo.
+// Numeric fields are packed with compression.VarInt, strings are prefixed with their packed length.
+// Returns ErrValueTooLong without writing anything if a string or the number of players exceeds
+// the length that ReadServerInfo accepts, or ErrValueOutOfRange if a numeric value exceeds the 32 bit range.
+func (s ServerInfo) WriteTo(w io.Writer) (int64, error) {
+	if err := s.validate(); err != nil {
+		return 0, err
+	}
+
+	var v compression.VarInt
+	v.Grow(maxBufferSize)
+
+	for _, str := range []string{s.Address, s.Version, s.Name, s.Hostname, s.Map, s.GameType} {
+		packString(&v, str)
+	}
+
+	passworded := 0
+	if s.Passworded {
+		passworded = 1
+	}
+
+	v.PackSlice([]int{
+		s.ServerFlags,
+		passworded,
+		s.SkillLevel,
+		s.NumPlayers,
+		s.MaxPlayers,
+		len(s.Players), // s.NumClients
+		s.MaxClients,
+	})
+
+	for _, player := range s.Players {
+		packString(&v, player.Name)
+		packString(&v, player.Clan)
+		v.PackSlice([]int{player.Type, player.Country, player.Score})
+	}
+
+	n, err := w.Write(v.Bytes())
+	return int64(n), err
+}
+
+// ReadServerInfo reads a server info that was written with ServerInfo.WriteTo from r.
+// Returns io.EOF if there is no data to be read at all, io.ErrUnexpectedEOF if the data is incomplete.
+func ReadServerInfo(r io.Reader) (ServerInfo, error) {
+	var info ServerInfo
+	d := serverInfoDecoder{r: r}
+
+	info.Address = d.string()
+	if d.err == io.EOF && d.n == 0 {
+		return ServerInfo{}, io.EOF
+	}
+	info.Version = d.string()
+	info.Name = d.string()
+	info.Hostname = d.string()
+	info.Map = d.string()
+	info.GameType = d.string()
+
+	info.ServerFlags = d.int()
+	info.Passworded = d.int() != 0
+	info.SkillLevel = d.int()
+	info.NumPlayers = d.int()
+	info.MaxPlayers = d.int()
+	info.NumClients = d.length()
+	info.MaxClients = d.int()
+
+	if d.err == nil {
+		info.Players = make([]PlayerInfo, 0, info.NumClients)
+	}
+
+	for i := 0; i < info.NumClients && d.err == nil; i++ {
+		player := PlayerInfo{}
+		player.Name = d.string()
+		player.Clan = d.string()
+		player.Type = d.int()
+		player.Country = d.int()
+		player.Score = d.int()
+
+		info.Players = append(info.Players, player)
+	}
+
+	if d.err != nil {
+		if d.err == io.EOF {
+			d.err = io.ErrUnexpectedEOF
+		}
+		return ServerInfo{}, d.err
+	}
+	return info, nil
+}
+
+// validate returns ErrValueTooLong if any length that is written by WriteTo is too long
+// to be read by ReadServerInfo, see serverInfoDecoder.length, and ErrValueOutOfRange
+// if any numeric value cannot be packed with compression.VarInt.
+func (s *ServerInfo) validate() error {
+	if len(s.Players) > maxBufferSize {
+		return fmt.Errorf("%w : %d players, max: %d", ErrValueTooLong, len(s.Players), maxBufferSize)
+	}
+
+	strs := []string{s.Address, s.Version, s.Name, s.Hostname, s.Map, s.GameType}
+	values := []int{s.ServerFlags, s.SkillLevel, s.NumPlayers, s.MaxPlayers, s.MaxClients}
+	for _, player := range s.Players {
+		strs = append(strs, player.Name, player.Clan)
+		values = append(values, player.Type, player.Country, player.Score)
+	}
+
+	for _, str := range strs {
+		if len(str) > maxBufferSize {
+			return fmt.Errorf("%w : string of %d bytes, max: %d", ErrValueTooLong, len(str), maxBufferSize)
+		}
+	}
+
+	for _, value := range values {
+		if value < math.MinInt32 || math.MaxInt32 < value {
+			return fmt.Errorf("%w : %d, expected 32 bit integer", ErrValueOutOfRange, value)
+		}
+	}
+	return nil
+}
+
+// packString packs the length of the string followed by the string itself.
+func packString(v *compression.VarInt, s string) {
+	v.Pack(len(s))
+	v.Write([]byte(s))
+}
+
+// serverInfoDecoder reads the values written by ServerInfo.WriteTo
+// After the first error, all following reads return zero values.
+type serverInfoDecoder struct {
+	r   io.Reader
+	n   int // number of bytes read
+	err error
+	buf [maxBufferSize]byte
+}
+
+// int reads the next packed integer byte by byte, until the extend bit is not set anymore
+func (d *serverInfoDecoder) int() int {
+	if d.err != nil {
+		return 0
+	}
+
+	size := 0
+	for size < compression.MaxBytesInVarInt {
+		d.read(d.buf[size : size+1])
+		if d.err != nil {
+			return 0
+		}
+		size++
+
+		if d.buf[size-1] < 0b10000000 {
+			break
+		}
+	}
+
+	v := compression.NewVarIntFrom(d.buf[:size])
+
+	var value int
+	value, d.err = v.Unpack()
+	return value
+}
+
+// length reads a packed length, which must be within the size of the buffer.
+func (d *serverInfoDecoder) length() int {
+	length := d.int()
+	if d.err == nil && (length < 0 || len(d.buf) < length) {
+		d.err = fmt.Errorf("%w : invalid length: %d", ErrMalformedResponseData, length)
+		return 0
+	}
+	return length
+}
+
+// string reads a length prefixed string
+func (d *serverInfoDecoder) string() string {
+	length := d.length()
+	if d.err != nil {
+		return ""
+	}
+
+	d.read(d.buf[:length])
+	if d.err != nil {
+		return ""
+	}
+	return string(d.buf[:length])
+}
+
+// read fills b completely
+func (d *serverInfoDecoder) read(b []byte) {
+	var n int
+	n, d.err = io.ReadFull(d.r, b)
+	d.n += n
+}
+
 // Token is used to request information from either master of game servers.
 // The token needs to be renewed via NewTokenRequestPacket()
 // followed by parsing the server's response with NewToken(responseMessage []byte) (Token, error)
@@ -423,11 +740,33 @@ func (ts *Token) Expired() bool {
 	return ts.expiresAt.Before(time.Now())
 }
 
+// ValidFor returns the remaining duration until the token expires.
+// If the token already expired, 0 is returned.
+func (ts *Token) ValidFor() time.Duration {
+	validFor := time.Until(ts.expiresAt)
+	if validFor < 0 {
+		return 0
+	}
+	return validFor
+}
+
 // Equal tests if two token contain the same payload
 func (ts *Token) Equal(t Token) bool {
 	return bytes.Equal(ts.Payload, t.Payload)
 }
 
+// MatchesResponse returns true if the response message contains the client token of the token in its header.
+// The response header contains the same tokens as the request header, but in reversed order:
+// first the client token followed by the server token.
+// Only the client token is compared, as servers may send a different server token after rotating their token seed.
+func (ts *Token) MatchesResponse(responseMessage []byte) bool {
+	if len(ts.Payload) < tokenPrefixSize || len(responseMessage) < tokenPrefixSize {
+		return false
+	}
+
+	return bytes.Equal(responseMessage[1:5], ts.Payload[5:9])
+}
+
 // String implements the Stringer interface and returns a stringrepresentation of the token
 func (ts *Token) String() string {
 	return fmt.Sprintf("Token(%d): Client: %d Server: %d Expires: %s", len(ts.Payload), ts.client, ts.server, ts.expiresAt.String())
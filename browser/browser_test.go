@@ -1,6 +1,16 @@
 package browser
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/bits"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestServerInfo_Equal(t *testing.T) {
 	type fields struct {
@@ -101,3 +111,189 @@ func TestServerInfo_Equal(t *testing.T) {
 		t.Fatalf("Wanted= %s, Parsed=%s", info.String(), parsedInfo.String())
 	}
 }
+
+func TestServerInfo_Key(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		want    string
+	}{
+		{"ipv4", "127.0.0.1:8303", "127.0.0.1:8303"},
+		{"ipv4-mapped ipv6", "[::ffff:127.0.0.1]:8303", "127.0.0.1:8303"},
+		{"ipv6", "[2001:0db8::0001]:8303", "[2001:db8::1]:8303"},
+		{"invalid", "invalid", "invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := ServerInfo{Address: tt.address}
+			if got := info.Key(); got != tt.want {
+				t.Errorf("ServerInfo.Key() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToken_ValidFor(t *testing.T) {
+	expired := Token{}
+	if expired.ValidFor() != 0 {
+		t.Fatalf("expected expired token to be valid for 0, got %s", expired.ValidFor())
+	}
+
+	token := Token{expiresAt: time.Now().Add(time.Minute)}
+	if validFor := token.ValidFor(); validFor <= 0 || validFor > time.Minute {
+		t.Fatalf("expected token to be valid for up to a minute, got %s", validFor)
+	}
+}
+
+func TestServerInfo_WriteTo(t *testing.T) {
+	infos := []ServerInfo{
+		{},
+		{
+			Address:     "127.0.0.1:8303",
+			Version:     "0.7.5",
+			Name:        "Simply zCatch",
+			Hostname:    "zcatch.example.com",
+			Map:         "ctf5",
+			GameType:    "zCatch",
+			ServerFlags: 3,
+			Passworded:  true,
+			SkillLevel:  2,
+			NumPlayers:  2,
+			MaxPlayers:  16,
+			NumClients:  3,
+			MaxClients:  64,
+			Players: []PlayerInfo{
+				{Name: "player1", Clan: "clan1", Type: 1, Country: 276, Score: -5},
+				{Name: "player2", Clan: "", Type: 1, Country: -1, Score: 1048576},
+				{Name: "spectator", Clan: "clan2", Type: 0, Country: 0, Score: 0},
+			},
+		},
+		{Address: "[2001:db8::1]:8303", Name: "no players", MaxClients: 16, Players: []PlayerInfo{}},
+	}
+
+	var buf bytes.Buffer
+	written := int64(0)
+	for _, info := range infos {
+		n, err := info.WriteTo(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		written += n
+	}
+
+	if written != int64(buf.Len()) {
+		t.Fatalf("ServerInfo.WriteTo() wrote %d bytes, but reported %d", buf.Len(), written)
+	}
+
+	for _, expected := range infos {
+		info, err := ReadServerInfo(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.Equal(expected) || info.Passworded != expected.Passworded {
+			t.Fatalf("ReadServerInfo() = %s, want %s", info.String(), expected.String())
+		}
+	}
+
+	if _, err := ReadServerInfo(&buf); err != io.EOF {
+		t.Fatalf("ReadServerInfo() error = %v, want %v", err, io.EOF)
+	}
+
+	// too long values cannot be read by ReadServerInfo and must not be written
+	tooLong := []ServerInfo{
+		{Name: strings.Repeat("x", maxBufferSize+1)},
+		{Players: []PlayerInfo{{Clan: strings.Repeat("x", maxBufferSize+1)}}},
+		{Players: make([]PlayerInfo, maxBufferSize+1)},
+	}
+	for _, info := range tooLong {
+		var tooLongBuf bytes.Buffer
+		n, err := info.WriteTo(&tooLongBuf)
+		if !errors.Is(err, ErrValueTooLong) {
+			t.Fatalf("ServerInfo.WriteTo() error = %v, want %v", err, ErrValueTooLong)
+		}
+		if n != 0 || tooLongBuf.Len() != 0 {
+			t.Fatalf("ServerInfo.WriteTo() wrote %d bytes, expected nothing to be written", tooLongBuf.Len())
+		}
+	}
+
+	// values outside of the 32 bit range cannot be packed, such values only exist with a 64 bit int
+	var tooBig, tooSmall int64 = math.MaxInt32 + 1, -1 << 40
+	outOfRange := []ServerInfo{
+		{ServerFlags: int(tooBig)},
+		{MaxClients: int(tooSmall)},
+		{Players: []PlayerInfo{{Score: int(tooBig)}}},
+		{Players: []PlayerInfo{{Country: int(tooSmall)}}},
+	}
+	if bits.UintSize == 32 {
+		outOfRange = nil
+	}
+	for _, info := range outOfRange {
+		var outOfRangeBuf bytes.Buffer
+		n, err := info.WriteTo(&outOfRangeBuf)
+		if !errors.Is(err, ErrValueOutOfRange) {
+			t.Fatalf("ServerInfo.WriteTo() error = %v, want %v", err, ErrValueOutOfRange)
+		}
+		if n != 0 || outOfRangeBuf.Len() != 0 {
+			t.Fatalf("ServerInfo.WriteTo() wrote %d bytes, expected nothing to be written", outOfRangeBuf.Len())
+		}
+	}
+
+	// the max length can still be read
+	maxLength := ServerInfo{Name: strings.Repeat("x", maxBufferSize)}
+	buf.Reset()
+	if _, err := maxLength.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := ReadServerInfo(&buf); err != nil || info.Name != maxLength.Name {
+		t.Fatalf("ReadServerInfo() error = %v, expected a name of %d bytes", err, maxBufferSize)
+	}
+
+	// truncated data
+	var single bytes.Buffer
+	infos[1].WriteTo(&single)
+	data := single.Bytes()
+
+	for _, size := range []int{1, len(data) / 2, len(data) - 1} {
+		_, err := ReadServerInfo(bytes.NewReader(data[:size]))
+		if err != io.ErrUnexpectedEOF {
+			t.Fatalf("ReadServerInfo() of %d/%d bytes error = %v, want %v", size, len(data), err, io.ErrUnexpectedEOF)
+		}
+	}
+}
+
+func TestResolveUDPAddrs(t *testing.T) {
+	addrs, err := resolveUDPAddrs(context.Background(), []string{"127.0.0.1:8283", "localhost:8283", "missing port", "[::1]:8303"})
+
+	var resolveErr *ResolveError
+	if !errors.As(err, &resolveErr) {
+		t.Fatalf("resolveUDPAddrs() error = %v, want a *ResolveError", err)
+	}
+	if len(resolveErr.Errors) != 1 {
+		t.Fatalf("expected a single error, got %v", resolveErr.Errors)
+	}
+
+	want := []string{"127.0.0.1:8283", "127.0.0.1:8283", "[::1]:8303"}
+	if len(addrs) != len(want) {
+		t.Fatalf("resolveUDPAddrs() = %v, want %v", addrs, want)
+	}
+	for idx, addr := range addrs {
+		if addr.String() != want[idx] {
+			t.Errorf("resolveUDPAddrs()[%d] = %s, want %s", idx, addr.String(), want[idx])
+		}
+	}
+}
+
+func TestResolveMasterServersCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	addrs, err := ResolveMasterServers(ctx)
+
+	var resolveErr *ResolveError
+	if !errors.As(err, &resolveErr) {
+		t.Fatalf("ResolveMasterServers() error = %v, want a *ResolveError", err)
+	}
+	if len(addrs) != 0 || len(resolveErr.Errors) != len(masterServerHostnameAddresses) {
+		t.Fatalf("expected one error per masterserver, got %d addresses and %d errors", len(addrs), len(resolveErr.Errors))
+	}
+}
@@ -1,6 +1,8 @@
 package browser
 
 import (
+	"errors"
+	"fmt"
 	"sync"
 	"time"
 )
@@ -27,7 +29,9 @@ func (esi *ExpiringServerInfo) Expired() bool {
 	return time.Now().After(esi.ExpiresAt)
 }
 
-// ConcurrentMap maps a server address ip:port to an expiring
+// ConcurrentMap maps a server address ip:port to an expiring server info.
+// The address is the ServerInfo.Key(), which is why there is at most one entry per server.
+// Adding an info of an already known server replaces the previous entry.
 type ConcurrentMap struct {
 	Map map[string]ExpiringServerInfo
 	sync.RWMutex
@@ -51,11 +55,11 @@ func (cm *ConcurrentMap) Add(si ServerInfo, expiresIn time.Duration) {
 	}
 
 	cm.Lock()
-	cm.Map[esi.Address] = esi
+	cm.Map[si.Key()] = esi
 	cm.Unlock()
 }
 
-// Get retrieves the ServerInfo
+// Get retrieves the ServerInfo of the passed ServerInfo.Key()
 func (cm *ConcurrentMap) Get(key string) (si ServerInfo, ok bool) {
 	cm.RLock()
 	esi, ok := cm.Map[key]
@@ -93,6 +97,21 @@ func (cm *ConcurrentMap) Values() (infos []ServerInfo) {
 	return
 }
 
+// Range calls f sequentially for each key and server info in the map.
+// If f returns false, the iteration is stopped.
+// The map is read locked during the iteration, which is why f must not call
+// any of the modifying methods like Add, Delete or Cleanup.
+func (cm *ConcurrentMap) Range(f func(key string, info ServerInfo) bool) {
+	cm.RLock()
+	defer cm.RUnlock()
+
+	for key, value := range cm.Map {
+		if !f(key, value.ServerInfo) {
+			return
+		}
+	}
+}
+
 // Cleanup removes all expired entries
 func (cm *ConcurrentMap) Cleanup() int {
 	cleanedUp := 0
@@ -107,3 +126,109 @@ func (cm *ConcurrentMap) Cleanup() int {
 
 	return cleanedUp
 }
+
+// NewTokenCache creates a new token cache
+func NewTokenCache(size int) *TokenCache {
+	return &TokenCache{
+		Map: make(map[string]Token, size),
+	}
+}
+
+// TokenCache maps a server address ip:port to the last token that was received from that server.
+// It allows to reuse a token for multiple requests to the same server, until the token expires,
+// which saves the token round trip before every request.
+// Servers bind their tokens to the client's address, which is why the cache is meant
+// to be used with connections that are reused for multiple requests.
+type TokenCache struct {
+	Map map[string]Token
+	sync.Mutex
+}
+
+// Add adds the token of the server with the passed address to the cache
+func (tc *TokenCache) Add(address string, t Token) {
+	tc.Lock()
+	tc.Map[address] = t
+	tc.Unlock()
+}
+
+// Get returns the token of the server with the passed address, if it is valid for at least
+// the passed duration. Expired tokens are removed from the cache.
+func (tc *TokenCache) Get(address string, validFor time.Duration) (t Token, ok bool) {
+	tc.Lock()
+	defer tc.Unlock()
+
+	t, ok = tc.Map[address]
+	if !ok {
+		return
+	}
+
+	if t.Expired() {
+		delete(tc.Map, address)
+		return Token{}, false
+	}
+
+	if t.ValidFor() < validFor {
+		return Token{}, false
+	}
+	return t, true
+}
+
+// Delete removes the token of the server with the passed address from the cache
+func (tc *TokenCache) Delete(address string) (ok bool) {
+	tc.Lock()
+	_, ok = tc.Map[address]
+	delete(tc.Map, address)
+	tc.Unlock()
+	return
+}
+
+// Fetch is the same as the package level Fetch, but it reuses the cached token of the connection's remote address
+// as long as it is still valid for at least minTokenValidity. A new token is only requested if there is no such token
+// or if the cached token expires while fetching the data, in which case the request is retried with the new token.
+// If the request fails, the cached token is removed, so that the next call requests a new token.
+func (tc *TokenCache) Fetch(packet string, rwd ReadWriteDeadliner, timeout time.Duration) (response []byte, err error) {
+	if err = validatePacket(packet); err != nil {
+		return
+	}
+
+	addr := remoteAddr(rwd)
+	if addr == nil {
+		// cannot be cached without an address
+		return Fetch(packet, rwd, timeout)
+	}
+	address := addr.String()
+
+	if timeout < minTimeout {
+		timeout = minTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		token, ok := tc.Get(address, minTokenValidity)
+		if !ok {
+			var resp []byte
+			resp, err = FetchToken(rwd, time.Until(deadline))
+			if err != nil {
+				return
+			}
+
+			token, err = ParseToken(resp)
+			if err != nil {
+				err = fmt.Errorf("failed to parse token: %w", err)
+				return
+			}
+			tc.Add(address, token)
+		}
+
+		response, err = FetchWithToken(packet, token, rwd, time.Until(deadline))
+		if err == nil {
+			return response, nil
+		}
+		tc.Delete(address)
+
+		if !errors.Is(err, ErrTokenExpired) || !time.Now().Before(deadline) {
+			return nil, err
+		}
+		// the token expired while fetching, retry with a new token
+	}
+}
@@ -1,6 +1,8 @@
 package browser
 
 import (
+	"bytes"
+	"net"
 	"testing"
 	"time"
 )
@@ -22,3 +24,141 @@ func TestExpiringServerInfo_Expired(t *testing.T) {
 		t.Fatal("didn't expire, even tho it should have expired.")
 	}
 }
+
+func TestConcurrentMap_AddUsesKey(t *testing.T) {
+	cm := NewConcurrentMap(2)
+
+	cm.Add(ServerInfo{Address: "1.2.3.4:8303", NumPlayers: 1}, 0)
+	cm.Add(ServerInfo{Address: "1.2.3.4:8303", NumPlayers: 2}, 0)
+	cm.Add(ServerInfo{Address: "[::ffff:1.2.3.4]:8303", NumPlayers: 3}, 0)
+
+	if cm.Len() != 1 {
+		t.Fatalf("expected a single entry, got %d", cm.Len())
+	}
+
+	info, ok := cm.Get("1.2.3.4:8303")
+	if !ok {
+		t.Fatal("expected entry to exist")
+	}
+	if info.NumPlayers != 3 {
+		t.Fatalf("expected the last added info, got %s", info.String())
+	}
+}
+
+func TestConcurrentMap_Range(t *testing.T) {
+	cm := NewConcurrentMap(3)
+	cm.Add(ServerInfo{Address: "127.0.0.1:8303", NumPlayers: 1}, 0)
+	cm.Add(ServerInfo{Address: "127.0.0.1:8304", NumPlayers: 2}, 0)
+	cm.Add(ServerInfo{Address: "127.0.0.1:8305", NumPlayers: 3}, 0)
+
+	players := 0
+	cm.Range(func(key string, info ServerInfo) bool {
+		if key != info.Key() {
+			t.Errorf("key %q does not match info key %q", key, info.Key())
+		}
+		players += info.NumPlayers
+		return true
+	})
+
+	if players != 6 {
+		t.Fatalf("expected to iterate over all entries, players: %d", players)
+	}
+
+	iterations := 0
+	cm.Range(func(key string, info ServerInfo) bool {
+		iterations++
+		return false
+	})
+
+	if iterations != 1 {
+		t.Fatalf("expected iteration to stop after the first entry, iterations: %d", iterations)
+	}
+}
+
+// newFakeInfoServer starts a fake server that answers token and server info requests.
+// Server info requests are only answered, if they contain the server token of fakeTokenResponse.
+func newFakeInfoServer(t *testing.T, tokenRequests *asyncCounter) (*net.UDPAddr, func()) {
+	return newFakeServer(t, 0, func(request []byte) []byte {
+		if response := fakeTokenResponse(request); response != nil {
+			tokenRequests.Inc()
+			return response
+		}
+		if bytes.HasSuffix(request, requestInfoRaw) && bytes.Equal(request[1:5], []byte{1, 2, 3, 4}) {
+			info := ServerInfo{Name: "fake server", MaxClients: 16}
+			data, _ := info.MarshalBinary()
+
+			return fakeResponse(request, sendInfoRaw, data)
+		}
+		return nil
+	})
+}
+
+func TestTokenCache_Fetch(t *testing.T) {
+	var tokenRequests asyncCounter
+	addr, stop := newFakeInfoServer(t, &tokenRequests)
+	defer stop()
+
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tc := NewTokenCache(1)
+	for i := 0; i < 3; i++ {
+		// the default timeout is longer than a token is valid, which must not prevent reusing the token.
+		resp, err := tc.Fetch("serverinfo", conn, TimeoutServers)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := ParseServerInfo(resp, addr.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Name != "fake server" {
+			t.Fatalf("unexpected server info: %s", info.String())
+		}
+	}
+
+	if tokenRequests.String() != "1" {
+		t.Fatalf("expected a single token request, got %s", tokenRequests.String())
+	}
+
+	if _, ok := tc.Get(addr.String(), minTokenValidity); !ok {
+		t.Fatal("expected the token to be cached")
+	}
+}
+
+func TestTokenCache_FetchExpiredToken(t *testing.T) {
+	var tokenRequests asyncCounter
+	addr, stop := newFakeInfoServer(t, &tokenRequests)
+	defer stop()
+
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// the cached token is not accepted by the server and expires while fetching,
+	// which must be retried with a new token.
+	tc := NewTokenCache(1)
+	tc.Add(addr.String(), Token{
+		Payload:   packToken(1, 0x09090909),
+		expiresAt: time.Now().Add(minTokenValidity + 200*time.Millisecond),
+	})
+
+	resp, err := tc.Fetch("serverinfo", conn, TimeoutServers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = ParseServerInfo(resp, addr.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	if tokenRequests.String() != "1" {
+		t.Fatalf("expected a single token request, got %s", tokenRequests.String())
+	}
+}
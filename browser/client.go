@@ -2,13 +2,40 @@ package browser
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"math"
 	"net"
+	"sort"
 	"sync"
 	"time"
 )
 
+// remoteAddr returns the remote address of the passed connection or nil, if it has none.
+func remoteAddr(conn interface{}) net.Addr {
+	if c, ok := conn.(interface{ RemoteAddr() net.Addr }); ok {
+		return c.RemoteAddr()
+	}
+	return nil
+}
+
+// writeBufferSize returns the write buffer size that is needed to send requests for the duration of timeout.
+// The size is computed in floating point before it is converted, so that large timeouts cannot overflow,
+// and it is always within [maxBufferSize, maxBufferSize * maxChunks].
+func writeBufferSize(timeout time.Duration) int {
+	const maxWriteBufferSize = maxBufferSize * maxChunks
+
+	size := maxBufferSize * timeout.Seconds()
+	if size < maxBufferSize {
+		return maxBufferSize
+	} else if size > maxWriteBufferSize {
+		return maxWriteBufferSize
+	}
+	return int(size)
+}
+
 // RequestToken writes the payload to w
 func RequestToken(w io.Writer) (err error) {
 	tokenReq := NewTokenRequestPacket()
@@ -37,52 +64,91 @@ func ReceiveToken(r io.Reader) (response []byte, err error) {
 	return response[:read], err
 }
 
-// FetchToken tries to fetch a token from the server for a specific duration at most. a timeout below 35 ms will be set to 35 ms
+// nextWriteBurst returns the number of requests that are sent in the round after a round with burst requests, in which no response was received.
+// The burst doubles after every round, starting at minWriteBurst, but it never exceeds maxWriteBurst.
+func nextWriteBurst(burst int) int {
+	burst *= 2
+	if burst < minWriteBurst {
+		return minWriteBurst
+	} else if burst > maxWriteBurst {
+		return maxWriteBurst
+	}
+	return burst
+}
+
+// FetchToken tries to fetch a token from the server for a specific duration at most. a timeout below 60 ms will be set to 60 ms
+// The token is requested in rounds, each round sends a burst of requests and waits for the response.
+// After every unanswered round the waiting time as well as the burst double, see nextWriteBurst.
+// The write deadline of rwd is set to the end of the timeout, so that rate limited writes cannot exceed it, see RateLimiter.Limit.
 func FetchToken(rwd ReadWriteDeadliner, timeout time.Duration) (response []byte, err error) {
 	if timeout < minTimeout {
 		timeout = minTimeout
 	}
 
-	begin := time.Now()
-	timeLeft := timeout
+	deadline := time.Now().Add(timeout)
+	rwd.SetWriteDeadline(deadline)
+
 	currentTimeout := minTimeout
-	writeBurst := 1.0
+	writeBurst := minWriteBurst
+	sent := 0
 
 	for {
-		timeLeft = timeout - time.Since(begin)
-		rwd.SetReadDeadline(time.Now().Add(currentTimeout))
-
-		if timeLeft <= 0 {
+		if !time.Now().Before(deadline) {
 			// early return, because timed out
-			err = ErrTimeout
+			err = fmt.Errorf("failed to fetch token: %w", ErrTimeout)
 			return
 		}
 
 		// send multiple requests
-		for i := 0.0; i < writeBurst; i += 1.0 {
+		for i := 0; i < writeBurst; i++ {
 			err = RequestToken(rwd)
-			if err != nil {
+			if errors.Is(err, ErrTimeout) && sent > 0 {
+				// the rate limiter does not allow any further request until the deadline,
+				// but the previous requests may still be answered.
+				currentTimeout = timeout
+				break
+			} else if err != nil {
+				err = fmt.Errorf("failed to request token: %w", err)
 				return
 			}
+			sent++
 		}
 
-		// wait for response
-		response, err = ReceiveToken(rwd)
-		if err == nil {
-			return
+		// wait for response, the deadline is set after writing, as the writes might have been delayed.
+		// Rejected responses are discarded without ending the round.
+		rwd.SetReadDeadline(readDeadline(currentTimeout, deadline))
+		for {
+			response, err = ReceiveToken(rwd)
+			if err == nil {
+				return
+			} else if !isRejectedResponse(err) {
+				break
+			}
 		}
 
 		// increase time & request burst
-		timeLeft = timeout - time.Since(begin)
-		if timeLeft <= currentTimeout {
-			currentTimeout = timeLeft
-		} else {
-			currentTimeout *= 2
-		}
-		writeBurst *= 1.2
+		currentTimeout *= 2
+		writeBurst = nextWriteBurst(writeBurst)
 	}
 }
 
+// isRejectedResponse returns true if the error was caused by a response that was received, but rejected,
+// e.g. a late response to a previous request, instead of by a failed read.
+func isRejectedResponse(err error) bool {
+	return errors.Is(err, ErrTokenMismatch) ||
+		errors.Is(err, ErrRequestResponseMismatch) ||
+		errors.Is(err, ErrInvalidResponseMessage) ||
+		errors.Is(err, ErrInvalidHeaderLength)
+}
+
+// readDeadline returns the deadline for waiting timeout from now on, but not beyond the passed deadline.
+func readDeadline(timeout time.Duration, deadline time.Time) time.Time {
+	if d := time.Now().Add(timeout); d.Before(deadline) {
+		return d
+	}
+	return deadline
+}
+
 // Request writes the payload into w.
 // w can be a buffer or a udp connection
 // packet can be one of:
@@ -98,6 +164,8 @@ func Request(packet string, token Token, w io.Writer) (err error) {
 		payload, err = NewServerCountRequestPacket(token)
 	case "serverinfo":
 		payload, err = NewServerInfoRequestPacket(token)
+	default:
+		err = fmt.Errorf("%w: %q", ErrUnknownPacketType, packet)
 	}
 	if err != nil {
 		return
@@ -112,6 +180,16 @@ func Request(packet string, token Token, w io.Writer) (err error) {
 	return
 }
 
+// validatePacket returns ErrUnknownPacketType if the packet cannot be requested with Request
+func validatePacket(packet string) error {
+	switch packet {
+	case "serverlist", "servercount", "serverinfo":
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownPacketType, packet)
+	}
+}
+
 // Receive reads the response message and evaluates its validity.
 // If the message is not valid it is still returned.
 func Receive(packet string, r io.Reader) (response []byte, err error) {
@@ -119,69 +197,103 @@ func Receive(packet string, r io.Reader) (response []byte, err error) {
 
 	read, err := r.Read(response)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to receive %s response: %w", packet, err)
 	}
 
 	response = response[:read]
 
 	if read == 0 {
-		return response, ErrInvalidResponseMessage
+		return response, fmt.Errorf("received empty %s response: %w", packet, ErrInvalidResponseMessage)
 	}
 
 	match, err := MatchResponse(response)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to match %s response: %w", packet, err)
 	}
 
 	if match != packet {
-		err = ErrRequestResponseMismatch
+		err = fmt.Errorf("expected %s response, got %s: %w", packet, match, ErrRequestResponseMismatch)
+	}
+	return response, err
+}
+
+// ReceiveWithDeadline is the same as Receive, but it sets the read deadline of rwd before reading,
+// so that it does not block longer than until the deadline, even if the caller did not set any deadline.
+// Receive can be used instead, if the deadlines are managed by the caller.
+func ReceiveWithDeadline(packet string, rwd ReadWriteDeadliner, deadline time.Time) (response []byte, err error) {
+	if err = rwd.SetReadDeadline(deadline); err != nil {
+		return nil, fmt.Errorf("failed to set read deadline for %s response: %w", packet, err)
+	}
+	return Receive(packet, rwd)
+}
+
+// ReceiveWithToken is the same as Receive, but it additionally validates that the response
+// contains the passed token of the request. Returns ErrTokenMismatch if that is not the case.
+func ReceiveWithToken(packet string, token Token, r io.Reader) (response []byte, err error) {
+	response, err = Receive(packet, r)
+	if err != nil {
+		return
+	}
+
+	if !token.MatchesResponse(response) {
+		err = fmt.Errorf("%s response does not match the requested token: %w", packet, ErrTokenMismatch)
 	}
 	return response, err
 }
 
 // FetchWithToken is the same as Fetch, but it retries fetching data for a specific time.
+// The data is requested in the same rounds with growing bursts of requests as the token in FetchToken.
 func FetchWithToken(packet string, token Token, rwd ReadWriteDeadliner, timeout time.Duration) (response []byte, err error) {
+	if err = validatePacket(packet); err != nil {
+		return
+	}
+
 	if timeout < minTimeout {
 		timeout = minTimeout
 	}
 
-	begin := time.Now()
-	timeLeft := timeout
+	deadline := time.Now().Add(timeout)
+	rwd.SetWriteDeadline(deadline)
+
 	currentTimeout := minTimeout
-	writeBurst := 1
+	writeBurst := minWriteBurst
+	sent := 0
 
 	for {
-		timeLeft = timeout - time.Since(begin)
-		rwd.SetReadDeadline(time.Now().Add(currentTimeout))
-
-		if timeLeft <= 0 {
+		if !time.Now().Before(deadline) {
 			// early return, because timed out
-			err = ErrTimeout
+			err = fmt.Errorf("failed to fetch %s: %w", packet, ErrTimeout)
 			return
 		}
 
 		// send multiple requests
 		for i := 0; i < writeBurst; i++ {
 			err = Request(packet, token, rwd)
-			if err != nil {
+			if errors.Is(err, ErrTimeout) && sent > 0 {
+				// see FetchToken
+				currentTimeout = timeout
+				break
+			} else if err != nil {
+				err = fmt.Errorf("failed to request %s: %w", packet, err)
 				return
 			}
+			sent++
 		}
 
-		// wait for response
-		response, err = Receive(packet, rwd)
-		if err == nil {
-			return
+		// wait for response, see FetchToken
+		rwd.SetReadDeadline(readDeadline(currentTimeout, deadline))
+		for {
+			response, err = ReceiveWithToken(packet, token, rwd)
+			if err == nil {
+				return
+			} else if !isRejectedResponse(err) {
+				break
+			}
 		}
 
 		// increase time & request burst
-		timeLeft = timeout - time.Since(begin)
-		if timeLeft <= currentTimeout {
-			currentTimeout = timeLeft
-		} else {
-			currentTimeout *= 2
-		}
-		writeBurst *= 2
+		currentTimeout *= 2
+		writeBurst = nextWriteBurst(writeBurst)
 	}
 }
 
@@ -211,6 +323,10 @@ func MatchResponse(responseMessage []byte) (string, error) {
 
 // Fetch sends the token, retrieves the response and sends the follow up packet request in order to receive the data response.
 func Fetch(packet string, rwd ReadWriteDeadliner, timeout time.Duration) (response []byte, err error) {
+	if err = validatePacket(packet); err != nil {
+		return
+	}
+
 	begin := time.Now()
 	resp, err := FetchToken(rwd, timeout)
 	if err != nil {
@@ -218,6 +334,7 @@ func Fetch(packet string, rwd ReadWriteDeadliner, timeout time.Duration) (respon
 	}
 	token, err := ParseToken(resp)
 	if err != nil {
+		err = fmt.Errorf("failed to parse token: %w", err)
 		return
 	}
 	timeLeft := timeout - time.Since(begin)
@@ -233,7 +350,25 @@ func Fetch(packet string, rwd ReadWriteDeadliner, timeout time.Duration) (respon
 // ServerInfos is a wrapper for ServerInfosWithTimeouts with prefedined parameters that have been deemed to work
 // with a rather low packet loss, but still being rather small.
 func ServerInfos() (infos []ServerInfo) {
-	return ServerInfosWithTimeouts(TimeoutMasterServers, TimeoutServers)
+	return ServerInfosWithOptions(DefaultScanOptions())
+}
+
+// newServerAddress validates the passed ip and port and creates a new address from them.
+func newServerAddress(ip string, port int) (*net.UDPAddr, error) {
+	ipAddr := net.ParseIP(ip)
+
+	if ipAddr == nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidIP, ip)
+	}
+
+	if port < 0 || math.MaxUint16 < port {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidPort, port)
+	}
+
+	return &net.UDPAddr{
+		IP:   ipAddr,
+		Port: port,
+	}, nil
 }
 
 // GetServerInfoWithTimeout fetches the server info from the passed address
@@ -242,43 +377,40 @@ func ServerInfos() (infos []ServerInfo) {
 func GetServerInfoWithTimeout(ip string, port int, timeout time.Duration) (ServerInfo, error) {
 	info := ServerInfo{}
 
-	ipAddr := net.ParseIP(ip)
-
-	if ipAddr == nil {
-		return info, ErrInvalidIP
+	srv, err := newServerAddress(ip, port)
+	if err != nil {
+		return info, err
 	}
 
-	if port < 0 || math.MaxUint16 < port {
-		return info, ErrInvalidPort
-	}
+	return fetchServerInfo(srv, timeout)
+}
+
+// fetchServerInfo fetches the server info from the passed address
+func fetchServerInfo(srv *net.UDPAddr, timeout time.Duration) (ServerInfo, error) {
+	info := ServerInfo{}
 
 	if timeout < minTimeout {
 		timeout = minTimeout
 	}
 
-	srv := &net.UDPAddr{
-		IP:   ipAddr,
-		Port: port,
-	}
-
 	conn, err := net.DialUDP("udp", nil, srv)
 	if err != nil {
-		return info, err
+		return info, fmt.Errorf("failed to connect to %s: %w", srv.String(), err)
 	}
 	defer conn.Close()
 
 	// increase buffers for writing and reading
 	conn.SetReadBuffer(maxBufferSize)
-	conn.SetWriteBuffer(int(maxBufferSize * timeout.Seconds()))
+	conn.SetWriteBuffer(writeBufferSize(timeout))
 
 	resp, err := Fetch("serverinfo", conn, timeout)
 	if err != nil {
-		return info, err
+		return info, fmt.Errorf("failed to get server info from %s: %w", srv.String(), err)
 	}
 
 	info, err = ParseServerInfo(resp, srv.String())
 	if err != nil {
-		return info, err
+		return info, fmt.Errorf("failed to parse server info from %s: %w", srv.String(), err)
 	}
 
 	return info, nil
@@ -291,17 +423,206 @@ func GetServerInfo(ip string, port int) (ServerInfo, error) {
 	return GetServerInfoWithTimeout(ip, port, TimeoutServers)
 }
 
+// GetServerInfos fetches the server infos of all of the passed addresses concurrently,
+// with at most maxConcurrent queries at the same time. If maxConcurrent is not positive,
+// all servers are queried at the same time.
+// Both returned slices are indexed like the passed addresses: for every address either the
+// error is nil and the info is set, or the info is empty and the error is set.
+func GetServerInfos(addrs []*net.UDPAddr, timeout time.Duration, maxConcurrent int) ([]ServerInfo, []error) {
+	if maxConcurrent <= 0 || len(addrs) < maxConcurrent {
+		maxConcurrent = len(addrs)
+	}
+
+	infos := make([]ServerInfo, len(addrs))
+	errs := make([]error, len(addrs))
+
+	semaphore := make(chan struct{}, maxConcurrent)
+
+	var wg sync.WaitGroup
+	wg.Add(len(addrs))
+
+	for idx, addr := range addrs {
+		idx, addr := idx, addr
+
+		semaphore <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			info, err := fetchServerInfo(addr, timeout)
+			if err != nil {
+				errs[idx] = err
+				return
+			}
+			infos[idx] = info
+		}()
+	}
+	wg.Wait()
+
+	return infos, errs
+}
+
+// PingServer measures the round trip time of a token request to the passed server.
+// The median of up to pingProbes probes is returned in order to smooth out jitter.
+// If no probe is answered within the timeout, ErrTimeout is returned.
+func PingServer(ip string, port int, timeout time.Duration) (time.Duration, error) {
+	srv, err := newServerAddress(ip, port)
+	if err != nil {
+		return 0, err
+	}
+
+	if timeout < minTimeout {
+		timeout = minTimeout
+	}
+
+	conn, err := net.DialUDP("udp", nil, srv)
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to %s: %w", srv.String(), err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(timeout)
+	rtts := make([]time.Duration, 0, pingProbes)
+
+	for i := 0; i < pingProbes; i++ {
+		rtt, err := pingProbe(conn, deadline)
+		if errors.Is(err, ErrTimeout) {
+			break
+		} else if err != nil {
+			return 0, fmt.Errorf("failed to ping %s: %w", srv.String(), err)
+		}
+		rtts = append(rtts, rtt)
+	}
+
+	if len(rtts) == 0 {
+		return 0, fmt.Errorf("failed to ping %s: %w", srv.String(), ErrTimeout)
+	}
+
+	return median(rtts), nil
+}
+
+// pingProbe sends a token request and returns the time until the response to that request is received.
+// The request is resent after every unanswered round, the rounds double in length, starting at minTimeout.
+// The round trip time is measured from the first send, so that slow responses are not attributed to a resent request.
+// Responses that do not contain the client token of the request, e.g. late responses of a previous probe, are discarded.
+// Returns ErrTimeout if no matching response is received until the deadline.
+func pingProbe(conn ReadWriteDeadliner, deadline time.Time) (time.Duration, error) {
+	request := NewTokenRequestPacket()
+	clientToken := request[8:12]
+	response := make([]byte, maxBufferSize)
+
+	begin := time.Now()
+	currentTimeout := minTimeout
+
+	for time.Now().Before(deadline) {
+		n, err := conn.Write(request)
+		if err != nil {
+			return 0, err
+		} else if n != len(request) {
+			return 0, ErrInvalidWrite
+		}
+
+		conn.SetReadDeadline(readDeadline(currentTimeout, deadline))
+		for {
+			read, err := conn.Read(response)
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				break
+			} else if err != nil {
+				return 0, err
+			}
+
+			if read == tokenResponseSize && bytes.Equal(response[3:7], clientToken) {
+				return time.Since(begin), nil
+			}
+		}
+		currentTimeout *= 2
+	}
+	return 0, ErrTimeout
+}
+
+// median sorts the passed durations and returns their median
+func median(durations []time.Duration) time.Duration {
+	sort.Slice(durations, func(i, j int) bool {
+		return durations[i] < durations[j]
+	})
+
+	mid := len(durations) / 2
+	if len(durations)%2 == 0 {
+		return (durations[mid-1] + durations[mid]) / 2
+	}
+	return durations[mid]
+}
+
+// ScanOptions configures a full scan of the masterservers and their registered game servers.
+// Timeouts below 60ms, including zero values, are set to 60ms, which is too short for most servers.
+// Use DefaultScanOptions() and change the returned options instead of a zero-valued ScanOptions literal.
+type ScanOptions struct {
+	// TimeoutMasterServers is the timeout per masterserver
+	TimeoutMasterServers time.Duration
+
+	// TimeoutServers is the timeout per game server
+	TimeoutServers time.Duration
+
+	// RateLimiter caps the aggregate number of outgoing packets of all scanning goroutines.
+	// If nil, the number of outgoing packets is not limited.
+	RateLimiter *RateLimiter
+
+	// ResolveMasterServers resolves the masterservers before the scan with ResolveMasterServers,
+	// instead of using the MasterServerAddresses that were resolved on import.
+	// If no masterserver can be resolved, MasterServerAddresses are used.
+	// Resolving takes at most TimeoutMasterServers, see resolveTimeout.
+	ResolveMasterServers bool
+}
+
+// resolveTimeout returns the timeout for resolving the masterservers of a scan.
+// DNS lookups need far longer than the 60ms minimum of the fetch functions, which is why
+// the package level TimeoutMasterServers is used if the timeout of the options is below that minimum.
+func resolveTimeout(opts ScanOptions) time.Duration {
+	if opts.TimeoutMasterServers < minTimeout {
+		return TimeoutMasterServers
+	}
+	return opts.TimeoutMasterServers
+}
+
+// DefaultScanOptions returns the options that are used by ServerInfos
+func DefaultScanOptions() ScanOptions {
+	return ScanOptions{
+		TimeoutMasterServers: TimeoutMasterServers,
+		TimeoutServers:       TimeoutServers,
+	}
+}
+
 // ServerInfosWithTimeouts retrieves the full serverlist with all of the server's infos from the masterservers as well as the individual servers
 // it is possible to set the masterserver and the per server timeouts manually.
 func ServerInfosWithTimeouts(timeoutMasterServer, timeoutServer time.Duration) (infos []ServerInfo) {
+	return ServerInfosWithOptions(ScanOptions{
+		TimeoutMasterServers: timeoutMasterServer,
+		TimeoutServers:       timeoutServer,
+	})
+}
+
+// ServerInfosWithOptions retrieves the full serverlist with all of the server's infos from the masterservers as well as the individual servers
+// using the passed scan options.
+func ServerInfosWithOptions(opts ScanOptions) (infos []ServerInfo) {
+	masterServers := MasterServerAddresses
+	if opts.ResolveMasterServers {
+		ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout(opts))
+		resolved, _ := ResolveMasterServers(ctx)
+		cancel()
+
+		if len(resolved) > 0 {
+			masterServers = resolved
+		}
+	}
+
 	cm := NewConcurrentMap(512)
 
 	var wg sync.WaitGroup
-	wg.Add(len(MasterServerAddresses))
+	wg.Add(len(masterServers))
 
-	for _, ms := range MasterServerAddresses {
+	for _, ms := range masterServers {
 		ms := ms
-		go fetchServersFromMasterServerAddress(ms, timeoutMasterServer, timeoutServer, &cm, &wg)
+		go fetchServersFromMasterServerAddress(ms, opts, &cm, &wg)
 	}
 
 	wg.Wait()
@@ -310,7 +631,7 @@ func ServerInfosWithTimeouts(timeoutMasterServer, timeoutServer time.Duration) (
 	return
 }
 
-func fetchServersFromMasterServerAddress(ms *net.UDPAddr, timeoutMasterServer, timeoutServer time.Duration, cm *ConcurrentMap, wg *sync.WaitGroup) {
+func fetchServersFromMasterServerAddress(ms *net.UDPAddr, opts ScanOptions, cm *ConcurrentMap, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	conn, err := net.DialUDP("udp", nil, ms)
@@ -320,7 +641,7 @@ func fetchServersFromMasterServerAddress(ms *net.UDPAddr, timeoutMasterServer, t
 	defer conn.Close()
 	conn.SetWriteBuffer(maxBufferSize * maxChunks)
 
-	resp, err := Fetch("serverlist", conn, timeoutMasterServer)
+	resp, err := Fetch("serverlist", opts.RateLimiter.Limit(conn), opts.TimeoutMasterServers)
 	if err != nil {
 		return
 	}
@@ -335,12 +656,12 @@ func fetchServersFromMasterServerAddress(ms *net.UDPAddr, timeoutMasterServer, t
 	infoWaiter.Add(len(servers))
 	for _, s := range servers {
 		s := s
-		go fetchServerInfoFromServerAddress(s, timeoutServer, cm, &infoWaiter)
+		go fetchServerInfoFromServerAddress(s, opts.TimeoutServers, opts.RateLimiter, cm, &infoWaiter)
 	}
 	infoWaiter.Wait()
 }
 
-func fetchServerInfoFromServerAddress(srv *net.UDPAddr, timeout time.Duration, cm *ConcurrentMap, wg *sync.WaitGroup) {
+func fetchServerInfoFromServerAddress(srv *net.UDPAddr, timeout time.Duration, rl *RateLimiter, cm *ConcurrentMap, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	conn, err := net.DialUDP("udp", nil, srv)
@@ -351,9 +672,9 @@ func fetchServerInfoFromServerAddress(srv *net.UDPAddr, timeout time.Duration, c
 
 	// increase buffers for writing and reading
 	conn.SetReadBuffer(maxBufferSize)
-	conn.SetWriteBuffer(int(maxBufferSize * timeout.Seconds()))
+	conn.SetWriteBuffer(writeBufferSize(timeout))
 
-	resp, err := Fetch("serverinfo", conn, timeout)
+	resp, err := Fetch("serverinfo", rl.Limit(conn), timeout)
 	if err != nil {
 		return
 	}
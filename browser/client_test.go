@@ -1,8 +1,10 @@
 package browser
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"math"
 	"net"
 	"sync"
 	"sync/atomic"
@@ -10,6 +12,113 @@ import (
 	"time"
 )
 
+var errFakeTimeout = errors.New("i/o timeout")
+
+// silentConn is a ReadWriteDeadliner that accepts every write, but never responds.
+// Reads block until the read deadline is reached.
+type silentConn struct {
+	mu           sync.Mutex
+	readDeadline time.Time
+	writes       int
+}
+
+func (c *silentConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	deadline := c.readDeadline
+	c.mu.Unlock()
+
+	time.Sleep(time.Until(deadline))
+	return 0, errFakeTimeout
+}
+
+func (c *silentConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	c.writes++
+	c.mu.Unlock()
+	return len(b), nil
+}
+
+func (c *silentConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *silentConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *silentConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *silentConn) Writes() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.writes
+}
+
+// newFakeServer starts a local udp server that answers every received packet with the
+// response of the handler. If the handler returns nil, no response is sent.
+// A positive delay delays every response, the requests are answered concurrently,
+// so that a delayed response does not delay the following ones. A zero delay answers immediately.
+// The returned function stops the server.
+func newFakeServer(t *testing.T, delay time.Duration, handler func(request []byte) []byte) (*net.UDPAddr, func()) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		buffer := make([]byte, maxBufferSize)
+		for {
+			read, addr, err := conn.ReadFromUDP(buffer)
+			if err != nil {
+				return
+			}
+
+			response := handler(buffer[:read])
+			if response == nil {
+				continue
+			}
+
+			if delay > 0 {
+				time.AfterFunc(delay, func() {
+					conn.WriteToUDP(response, addr)
+				})
+			} else {
+				conn.WriteToUDP(response, addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().(*net.UDPAddr), func() { conn.Close() }
+}
+
+// fakeTokenResponse answers token requests with a token response.
+func fakeTokenResponse(request []byte) []byte {
+	if len(request) != len(NewTokenRequestPacket()) {
+		return nil
+	}
+	response := make([]byte, tokenResponseSize)
+	copy(response[3:7], request[8:12]) // client token
+	copy(response[8:12], []byte{1, 2, 3, 4})
+	return response
+}
+
+// fakeResponse creates a response to the passed request with the tokens of the request
+// in the response header, followed by the payloads.
+func fakeResponse(request []byte, payloads ...[]byte) []byte {
+	response := make([]byte, tokenPrefixSize, maxBufferSize)
+	copy(response[1:5], request[5:9])
+	copy(response[5:9], request[1:5])
+	for _, payload := range payloads {
+		response = append(response, payload...)
+	}
+	return response
+}
+
 type asyncCounter int64
 
 func (ac *asyncCounter) Inc() {
@@ -223,3 +332,391 @@ func TestServerInfo(t *testing.T) {
 		})
 	}
 }
+
+func TestFetchErrorsAreWrapped(t *testing.T) {
+	_, err := FetchToken(&silentConn{}, minTimeout)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("FetchToken() error = %v, want %v", err, ErrTimeout)
+	}
+
+	token := Token{Payload: make([]byte, tokenPrefixSize), expiresAt: time.Now().Add(time.Minute)}
+	_, err = FetchWithToken("serverinfo", token, &silentConn{}, minTimeout)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("FetchWithToken() error = %v, want %v", err, ErrTimeout)
+	}
+
+	_, err = Fetch("serverinfo", &silentConn{}, minTimeout)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Fetch() error = %v, want %v", err, ErrTimeout)
+	}
+
+	response := make([]byte, tokenPrefixSize, tokenPrefixSize+len(sendServerListRaw))
+	response = append(response, sendServerListRaw...)
+	_, err = Receive("serverinfo", bytes.NewReader(response))
+	if !errors.Is(err, ErrRequestResponseMismatch) {
+		t.Fatalf("Receive() error = %v, want %v", err, ErrRequestResponseMismatch)
+	}
+
+	_, err = GetServerInfoWithTimeout("not an ip", 8303, minTimeout)
+	if !errors.Is(err, ErrInvalidIP) {
+		t.Fatalf("GetServerInfoWithTimeout() error = %v, want %v", err, ErrInvalidIP)
+	}
+}
+
+func TestWriteBufferSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		want    int
+	}{
+		{"negative timeout", -time.Second, maxBufferSize},
+		{"zero timeout", 0, maxBufferSize},
+		{"minimum timeout", minTimeout, maxBufferSize},
+		{"two seconds", 2 * time.Second, 2 * maxBufferSize},
+		{"several hours", 5 * time.Hour, maxBufferSize * maxChunks},
+		{"max duration", time.Duration(math.MaxInt64), maxBufferSize * maxChunks},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := writeBufferSize(tt.timeout); got != tt.want {
+				t.Errorf("writeBufferSize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPingServer(t *testing.T) {
+	addr, stop := newFakeServer(t, 0, fakeTokenResponse)
+	defer stop()
+
+	rtt, err := PingServer(addr.IP.String(), addr.Port, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rtt <= 0 || rtt > time.Second {
+		t.Fatalf("PingServer() = %s, expected a positive round trip time below the timeout", rtt)
+	}
+
+	// the responses take longer than the first rounds of a probe, so that late responses
+	// of a previous probe arrive while the next probe is waiting and must not be counted.
+	const delay = 100 * time.Millisecond
+	delayed, stopDelayed := newFakeServer(t, delay, fakeTokenResponse)
+	defer stopDelayed()
+
+	rtt, err = PingServer(delayed.IP.String(), delayed.Port, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rtt < delay {
+		t.Fatalf("PingServer() = %s, expected at least the response delay of %s", rtt, delay)
+	}
+
+	silent, stopSilent := newFakeServer(t, 0, func([]byte) []byte { return nil })
+	defer stopSilent()
+	_, err = PingServer(silent.IP.String(), silent.Port, minTimeout)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("PingServer() error = %v, want %v", err, ErrTimeout)
+	}
+
+	_, err = PingServer("127.0.0.1", -1, minTimeout)
+	if !errors.Is(err, ErrInvalidPort) {
+		t.Fatalf("PingServer() error = %v, want %v", err, ErrInvalidPort)
+	}
+}
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		name      string
+		durations []time.Duration
+		want      time.Duration
+	}{
+		{"single", []time.Duration{3}, 3},
+		{"odd", []time.Duration{9, 1, 3}, 3},
+		{"even", []time.Duration{4, 1, 2, 9}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := median(tt.durations); got != tt.want {
+				t.Errorf("median() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReceiveWithToken(t *testing.T) {
+	token := Token{Payload: packToken(1234, 5678), expiresAt: time.Now().Add(time.Minute)}
+	other := Token{Payload: packToken(4321, 5678), expiresAt: time.Now().Add(time.Minute)}
+
+	request, err := NewServerInfoRequestPacket(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := fakeResponse(request, sendInfoRaw)
+	_, err = ReceiveWithToken("serverinfo", token, bytes.NewReader(response))
+	if err != nil {
+		t.Fatalf("ReceiveWithToken() error = %v, want nil", err)
+	}
+
+	_, err = ReceiveWithToken("serverinfo", other, bytes.NewReader(response))
+	if !errors.Is(err, ErrTokenMismatch) {
+		t.Fatalf("ReceiveWithToken() error = %v, want %v", err, ErrTokenMismatch)
+	}
+
+	// the server token of the response may differ, e.g. after the server rotated its token seed.
+	rotated := fakeResponse(request, sendInfoRaw)
+	copy(rotated[5:9], []byte{9, 9, 9, 9})
+	_, err = ReceiveWithToken("serverinfo", token, bytes.NewReader(rotated))
+	if err != nil {
+		t.Fatalf("ReceiveWithToken() error = %v, want nil for a different server token", err)
+	}
+
+	_, err = ReceiveWithToken("serverlist", token, bytes.NewReader(response))
+	if !errors.Is(err, ErrRequestResponseMismatch) {
+		t.Fatalf("ReceiveWithToken() error = %v, want %v", err, ErrRequestResponseMismatch)
+	}
+}
+
+func TestUnknownPacketType(t *testing.T) {
+	token := Token{Payload: make([]byte, tokenPrefixSize), expiresAt: time.Now().Add(time.Minute)}
+
+	for _, packet := range []string{"nonsense", ""} {
+		var buf bytes.Buffer
+		err := Request(packet, token, &buf)
+		if !errors.Is(err, ErrUnknownPacketType) {
+			t.Fatalf("Request(%q) error = %v, want %v", packet, err, ErrUnknownPacketType)
+		}
+		if buf.Len() != 0 {
+			t.Fatalf("Request(%q) wrote %d bytes", packet, buf.Len())
+		}
+
+		conn := &silentConn{}
+		_, err = Fetch(packet, conn, time.Minute)
+		if !errors.Is(err, ErrUnknownPacketType) {
+			t.Fatalf("Fetch(%q) error = %v, want %v", packet, err, ErrUnknownPacketType)
+		}
+
+		_, err = FetchWithToken(packet, token, conn, time.Minute)
+		if !errors.Is(err, ErrUnknownPacketType) {
+			t.Fatalf("FetchWithToken(%q) error = %v, want %v", packet, err, ErrUnknownPacketType)
+		}
+
+		if conn.Writes() != 0 {
+			t.Fatalf("expected no writes for packet %q, got %d", packet, conn.Writes())
+		}
+	}
+}
+
+func TestReceiveWithDeadline(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	// no response
+	begin := time.Now()
+	_, err := ReceiveWithDeadline("serverinfo", client, time.Now().Add(minTimeout))
+	if err == nil {
+		t.Fatal("ReceiveWithDeadline() expected an error, because no response was sent")
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Fatalf("ReceiveWithDeadline() blocked for %s", elapsed)
+	}
+
+	// response
+	response := make([]byte, tokenPrefixSize, tokenPrefixSize+len(sendInfoRaw))
+	response = append(response, sendInfoRaw...)
+	go server.Write(response)
+
+	got, err := ReceiveWithDeadline("serverinfo", client, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, response) {
+		t.Fatalf("ReceiveWithDeadline() = %v, want %v", got, response)
+	}
+}
+
+func TestGetServerInfos(t *testing.T) {
+	const silentIdx = 2
+
+	addrs := make([]*net.UDPAddr, 0, 5)
+	for i := 0; i < 5; i++ {
+		if i == silentIdx {
+			silent, stop := newFakeServer(t, 0, func([]byte) []byte { return nil })
+			defer stop()
+			addrs = append(addrs, silent)
+			continue
+		}
+
+		name := fmt.Sprintf("server %d", i)
+		addr, stop := newFakeServer(t, 0, func(request []byte) []byte {
+			if response := fakeTokenResponse(request); response != nil {
+				return response
+			}
+			info := ServerInfo{Name: name, MaxClients: 16}
+			data, _ := info.MarshalBinary()
+			return fakeResponse(request, sendInfoRaw, data)
+		})
+		defer stop()
+		addrs = append(addrs, addr)
+	}
+
+	infos, errs := GetServerInfos(addrs, 500*time.Millisecond, 2)
+
+	if len(infos) != len(addrs) || len(errs) != len(addrs) {
+		t.Fatalf("expected %d server infos and errors, got %d and %d", len(addrs), len(infos), len(errs))
+	}
+
+	for idx := range addrs {
+		if idx == silentIdx {
+			if !errors.Is(errs[idx], ErrTimeout) {
+				t.Errorf("GetServerInfos() error at index %d = %v, want %v", idx, errs[idx], ErrTimeout)
+			}
+			if !infos[idx].Empty() {
+				t.Errorf("expected an empty server info at index %d, got %s", idx, infos[idx].String())
+			}
+			continue
+		}
+
+		if errs[idx] != nil {
+			t.Errorf("GetServerInfos() error at index %d = %v, want nil", idx, errs[idx])
+		}
+		if infos[idx].Name != fmt.Sprintf("server %d", idx) || infos[idx].Address != addrs[idx].String() {
+			t.Errorf("unexpected server info at index %d: %s", idx, infos[idx].String())
+		}
+	}
+}
+
+func TestResolveTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		want    time.Duration
+	}{
+		{"zero value", 0, TimeoutMasterServers},
+		{"below minimum", minTimeout - 1, TimeoutMasterServers},
+		{"minimum", minTimeout, minTimeout},
+		{"custom", 2 * time.Second, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveTimeout(ScanOptions{TimeoutMasterServers: tt.timeout}); got != tt.want {
+				t.Errorf("resolveTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// countingConn counts the writes to the wrapped connection
+type countingConn struct {
+	*net.UDPConn
+	writes int
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	c.writes++
+	return c.UDPConn.Write(b)
+}
+
+func TestFetchWithTokenDiscardsStaleResponses(t *testing.T) {
+	const delay = 100 * time.Millisecond
+
+	addr, stop := newFakeServer(t, delay, func(request []byte) []byte {
+		if response := fakeTokenResponse(request); response != nil {
+			return response
+		}
+		if bytes.HasSuffix(request, requestInfoRaw) {
+			info := ServerInfo{Name: "fake server", MaxClients: 16}
+			data, _ := info.MarshalBinary()
+			return fakeResponse(request, sendInfoRaw, data)
+		}
+		return nil
+	})
+	defer stop()
+
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	token, err := ParseToken(fakeTokenResponse(NewTokenRequestPacket()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the responses to these token requests arrive in the second round of fetching the info,
+	// before the response to the info request, like the late responses to the bursts of a previous FetchToken.
+	for i := 0; i < 5; i++ {
+		if err = RequestToken(conn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	counting := &countingConn{UDPConn: conn}
+	resp, err := FetchWithToken("serverinfo", token, counting, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ParseServerInfo(resp, addr.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first round of 60ms is not answered, the response to its request arrives during the second round
+	// of two requests. Every stale response that ended a round would double the next burst.
+	if counting.writes != 3 {
+		t.Fatalf("expected 3 info requests, got %d", counting.writes)
+	}
+}
+
+func TestNextWriteBurst(t *testing.T) {
+	tests := []struct {
+		burst int
+		want  int
+	}{
+		{-1, minWriteBurst},
+		{0, minWriteBurst},
+		{1, 2},
+		{2, 4},
+		{maxWriteBurst / 2, maxWriteBurst},
+		{maxWriteBurst, maxWriteBurst},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("burst %d", tt.burst), func(t *testing.T) {
+			if got := nextWriteBurst(tt.burst); got != tt.want {
+				t.Errorf("nextWriteBurst() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchWriteBursts(t *testing.T) {
+	// The rounds wait for 60ms, 120ms and 240ms, the last round is cut at the timeout.
+	// The bursts of those rounds are 1, 2 and 4 requests.
+	const (
+		timeout = 7 * minTimeout
+		writes  = 1 + 2 + 4
+	)
+
+	conn := &silentConn{}
+	_, err := FetchToken(conn, timeout)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("FetchToken() error = %v, want %v", err, ErrTimeout)
+	}
+	if conn.Writes() != writes {
+		t.Errorf("FetchToken() wrote %d requests, want %d", conn.Writes(), writes)
+	}
+
+	conn = &silentConn{}
+	token := Token{Payload: make([]byte, tokenPrefixSize), expiresAt: time.Now().Add(time.Minute)}
+	_, err = FetchWithToken("serverinfo", token, conn, timeout)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("FetchWithToken() error = %v, want %v", err, ErrTimeout)
+	}
+	if conn.Writes() != writes {
+		t.Errorf("FetchWithToken() wrote %d requests, want %d", conn.Writes(), writes)
+	}
+}
@@ -2,11 +2,42 @@ package browser
 
 import (
 	"bytes"
+	"fmt"
 	"math/rand"
 	"net"
 	"time"
 )
 
+// ParseError is returned by the parsing functions, if a response cannot be parsed.
+// It contains the raw response, which allows to inspect the bytes that caused the error.
+type ParseError struct {
+	// Raw is a copy of the whole response that was passed to the parsing function.
+	Raw []byte
+	// Offset is the offset in Raw of the part that could not be parsed,
+	// e.g. the start of the field of a server info that could not be parsed.
+	Offset int
+	// Err is the underlying error, e.g. ErrInvalidResponseMessage
+	Err error
+}
+
+// newParseError creates a new parse error with a copy of the raw response
+func newParseError(raw []byte, offset int, err error) *ParseError {
+	return &ParseError{
+		Raw:    append([]byte(nil), raw...),
+		Offset: offset,
+		Err:    err,
+	}
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("failed to parse response of %d bytes at offset %d: %v", len(e.Raw), e.Offset, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
 // NewTokenRequestPacket generates a new token request packet that can be
 // used to request for a new server token
 func NewTokenRequestPacket() TokenRequestPacket {
@@ -82,9 +113,10 @@ func ParseToken(serverResponse []byte) (Token, error) {
 }
 
 // ParseServerList parses the response server list
+// Returns a *ParseError if the response cannot be parsed.
 func ParseServerList(serverResponse []byte) (ServerList, error) {
 	if len(serverResponse) < tokenPrefixSize+len(sendServerListRaw) {
-		return nil, ErrInvalidResponseMessage
+		return nil, newParseError(serverResponse, 0, ErrInvalidResponseMessage)
 	}
 
 	//newTokenFromFollowUpRequest(serverResponse[:tokenPrefixSize])
@@ -92,7 +124,7 @@ func ParseServerList(serverResponse []byte) (ServerList, error) {
 	responseHeaderRaw := serverResponse[tokenPrefixSize : tokenPrefixSize+len(sendServerListRaw)]
 
 	if !bytes.Equal(responseHeaderRaw, sendServerListRaw) {
-		return nil, ErrUnexpectedResponseHeader
+		return nil, newParseError(serverResponse, tokenPrefixSize, ErrUnexpectedResponseHeader)
 	}
 
 	data := serverResponse[tokenPrefixSize+len(sendServerListRaw):]
@@ -134,21 +166,22 @@ func ParseServerList(serverResponse []byte) (ServerList, error) {
 }
 
 // ParseServerCount parses the response and returns the number of currently registered servers.
+// Returns a *ParseError if the response cannot be parsed.
 func ParseServerCount(serverResponse []byte) (int, error) {
 	if len(serverResponse) < tokenPrefixSize+len(sendServerListRaw) {
-		return 0, ErrInvalidResponseMessage
+		return 0, newParseError(serverResponse, 0, ErrInvalidResponseMessage)
 	}
 
 	responseHeaderRaw := serverResponse[tokenPrefixSize : tokenPrefixSize+len(sendServerListRaw)]
 
 	if !bytes.Equal(responseHeaderRaw, sendServerCountRaw) {
-		return 0, ErrUnexpectedResponseHeader
+		return 0, newParseError(serverResponse, tokenPrefixSize, ErrUnexpectedResponseHeader)
 	}
 
 	data := serverResponse[tokenPrefixSize+len(sendServerListRaw):]
 
 	if len(data) > 4 {
-		return 0, ErrInvalidResponseMessage
+		return 0, newParseError(serverResponse, tokenPrefixSize+len(sendServerListRaw), ErrInvalidResponseMessage)
 	}
 
 	count := 0
@@ -160,22 +193,23 @@ func ParseServerCount(serverResponse []byte) (int, error) {
 }
 
 // ParseServerInfo parses the serrver's server info response
+// Returns a *ParseError if the response cannot be parsed.
 func ParseServerInfo(serverResponse []byte, address string) (info ServerInfo, err error) {
 	if len(serverResponse) < tokenPrefixSize+len(sendInfoRaw) {
-		return ServerInfo{}, ErrInvalidResponseMessage
+		return ServerInfo{}, newParseError(serverResponse, 0, ErrInvalidResponseMessage)
 	}
 
 	responseHeaderRaw := serverResponse[tokenPrefixSize : tokenPrefixSize+len(sendInfoRaw)]
 
 	if !bytes.Equal(responseHeaderRaw, sendInfoRaw) {
-		return ServerInfo{}, ErrUnexpectedResponseHeader
+		return ServerInfo{}, newParseError(serverResponse, tokenPrefixSize, ErrUnexpectedResponseHeader)
 	}
 
 	data := serverResponse[tokenPrefixSize+len(sendInfoRaw):]
 
-	err = info.UnmarshalBinary(data)
+	offset, err := info.unmarshal(data)
 	if err != nil {
-		return ServerInfo{}, err
+		return ServerInfo{}, newParseError(serverResponse, tokenPrefixSize+len(sendInfoRaw)+offset, err)
 	}
 	info.Address = address
 	return
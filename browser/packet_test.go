@@ -1,6 +1,8 @@
 package browser
 
 import (
+	"bytes"
+	"errors"
 	"reflect"
 	"testing"
 )
@@ -162,3 +164,113 @@ func TestParseServerList(t *testing.T) {
 		})
 	}
 }
+
+func TestParseServerInfoVersionAndFlags(t *testing.T) {
+	header := make([]byte, tokenPrefixSize)
+	header = append(header, sendInfoRaw...)
+
+	packet := func(flags byte) []byte {
+		data := append([]byte(nil), header...)
+		data = append(data, "0.7.5\x00zCatch\x00\x00ctf5\x00zCatch\x00"...)
+		data = append(data, flags, 0) // server flags & skill level
+		data = append(data, 1, 16, 1, 16)
+		data = append(data, "player\x00clan\x00"...)
+		data = append(data, 0, 5, 1) // country, score, type
+		return data
+	}
+
+	tests := []struct {
+		name           string
+		serverResponse []byte
+		wantPassworded bool
+		wantFlags      int
+	}{
+		{"open server", packet(0x00), false, 0},
+		{"passworded server", packet(0x01), true, 1},
+		{"passworded server with other flags", packet(0x03), true, 3},
+		{"open server with other flags", packet(0x02), false, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := ParseServerInfo(tt.serverResponse, "127.0.0.1:8303")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Version != "0.7.5" {
+				t.Errorf("ParseServerInfo() Version = %q, want %q", info.Version, "0.7.5")
+			}
+			if info.Passworded != tt.wantPassworded {
+				t.Errorf("ParseServerInfo() Passworded = %v, want %v", info.Passworded, tt.wantPassworded)
+			}
+			if info.ServerFlags != tt.wantFlags {
+				t.Errorf("ParseServerInfo() ServerFlags = %v, want %v", info.ServerFlags, tt.wantFlags)
+			}
+			if len(info.Players) != 1 || info.Players[0].Name != "player" {
+				t.Errorf("ParseServerInfo() Players = %v", info.Players)
+			}
+		})
+	}
+}
+
+func TestParseError(t *testing.T) {
+	header := make([]byte, tokenPrefixSize)
+
+	malformedInfo := append(append([]byte(nil), header...), sendInfoRaw...)
+	malformedInfo = append(malformedInfo, "0.7.5\x00name\x00"...)
+
+	infoHeader := append(append([]byte(nil), header...), sendInfoRaw...)
+	infoFields := append(append([]byte(nil), infoHeader...), "v\x00n\x00h\x00m\x00g\x00"...)
+	infoFields = append(infoFields, 0, 0) // server flags, skill level
+
+	// the first player is complete, the name of the second one is not terminated
+	truncatedPlayer := append(append([]byte(nil), infoFields...), 1, 2, 2, 2)
+	truncatedPlayer = append(truncatedPlayer, "p1\x00c1\x00"...)
+	truncatedPlayer = append(truncatedPlayer, 0, 0, 0)
+	truncatedPlayerOffset := len(truncatedPlayer)
+	truncatedPlayer = append(truncatedPlayer, "p2"...)
+
+	negativeClients := append(append([]byte(nil), infoFields...), 1, 2, 0b01000000, 2) // -1 clients
+
+	countHeader := append(append([]byte(nil), header...), sendServerCountRaw...)
+	wrongInfoHeader := append(append([]byte(nil), countHeader...), 0) // as long as a server info header
+
+	tests := []struct {
+		name       string
+		parse      func(raw []byte) error
+		raw        []byte
+		wantOffset int
+		wantErr    error
+	}{
+		{"server info too short", func(raw []byte) error { _, err := ParseServerInfo(raw, ""); return err }, header, 0, ErrInvalidResponseMessage},
+		{"server info wrong header", func(raw []byte) error { _, err := ParseServerInfo(raw, ""); return err }, wrongInfoHeader, tokenPrefixSize, ErrUnexpectedResponseHeader},
+		{"server info malformed data", func(raw []byte) error { _, err := ParseServerInfo(raw, ""); return err }, malformedInfo, len(malformedInfo), ErrMalformedResponseData},
+		{"server info truncated player", func(raw []byte) error { _, err := ParseServerInfo(raw, ""); return err }, truncatedPlayer, truncatedPlayerOffset, ErrMalformedResponseData},
+		{"server info negative clients", func(raw []byte) error { _, err := ParseServerInfo(raw, ""); return err }, negativeClients, len(infoFields) + 2, ErrMalformedResponseData},
+		{"server list too short", func(raw []byte) error { _, err := ParseServerList(raw); return err }, header, 0, ErrInvalidResponseMessage},
+		{"server list wrong header", func(raw []byte) error { _, err := ParseServerList(raw); return err }, countHeader, tokenPrefixSize, ErrUnexpectedResponseHeader},
+		{"server count too short", func(raw []byte) error { _, err := ParseServerCount(raw); return err }, header, 0, ErrInvalidResponseMessage},
+		{"server count too long", func(raw []byte) error { _, err := ParseServerCount(raw); return err }, append(append([]byte(nil), countHeader...), 1, 2, 3, 4, 5), tokenPrefixSize + len(sendServerCountRaw), ErrInvalidResponseMessage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.parse(tt.raw)
+
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("expected a *ParseError, got %v", err)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(parseErr.Raw, tt.raw) {
+				t.Errorf("ParseError.Raw = %v, want %v", parseErr.Raw, tt.raw)
+			}
+			if &parseErr.Raw[0] == &tt.raw[0] {
+				t.Error("ParseError.Raw is not a copy of the response")
+			}
+			if parseErr.Offset != tt.wantOffset {
+				t.Errorf("ParseError.Offset = %d, want %d", parseErr.Offset, tt.wantOffset)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package browser
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Querier holds a single udp connection to a server, which is reused for every query.
+// The received token is reused as well until shortly before it expires, which is why repeated queries
+// of the same server skip the token round trip that the one-shot functions like
+// GetServerInfoWithTimeout need before every query.
+// Queries of the same Querier are executed sequentially, late responses of a previous query
+// that are still queued on the connection are discarded before every query.
+type Querier struct {
+	addr   *net.UDPAddr
+	conn   *net.UDPConn
+	tokens *TokenCache
+	mu     sync.Mutex
+}
+
+// NewQuerier connects to the passed server address.
+// The returned Querier must be closed with Close.
+func NewQuerier(addr *net.UDPAddr) (*Querier, error) {
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr.String(), err)
+	}
+
+	// increase buffers for writing and reading
+	conn.SetReadBuffer(maxBufferSize)
+	conn.SetWriteBuffer(maxBufferSize * maxChunks)
+
+	return &Querier{
+		addr:   addr,
+		conn:   conn,
+		tokens: NewTokenCache(1),
+	}, nil
+}
+
+// Address returns the address of the queried server
+func (q *Querier) Address() *net.UDPAddr {
+	return q.addr
+}
+
+// ServerInfo fetches the server info of the game server
+func (q *Querier) ServerInfo(timeout time.Duration) (ServerInfo, error) {
+	resp, err := q.fetch("serverinfo", timeout)
+	if err != nil {
+		return ServerInfo{}, fmt.Errorf("failed to get server info from %s: %w", q.addr.String(), err)
+	}
+
+	info, err := ParseServerInfo(resp, q.addr.String())
+	if err != nil {
+		return ServerInfo{}, fmt.Errorf("failed to parse server info from %s: %w", q.addr.String(), err)
+	}
+	return info, nil
+}
+
+// ServerCount fetches the number of registered servers from the masterserver
+func (q *Querier) ServerCount(timeout time.Duration) (int, error) {
+	resp, err := q.fetch("servercount", timeout)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get server count from %s: %w", q.addr.String(), err)
+	}
+
+	count, err := ParseServerCount(resp)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse server count from %s: %w", q.addr.String(), err)
+	}
+	return count, nil
+}
+
+// Close closes the underlying connection
+func (q *Querier) Close() error {
+	return q.conn.Close()
+}
+
+func (q *Querier) fetch(packet string, timeout time.Duration) ([]byte, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.drain()
+	return q.tokens.Fetch(packet, q.conn, timeout)
+}
+
+// drain discards all responses that are already queued on the connection.
+// Those are late responses to the redundant requests of a previous query, which contain the same
+// cached token and would otherwise be accepted as the response to the next query.
+func (q *Querier) drain() {
+	q.conn.SetReadDeadline(time.Now().Add(drainTimeout))
+
+	buffer := make([]byte, maxBufferSize)
+	for {
+		if _, err := q.conn.Read(buffer); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package browser
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestQuerier(t *testing.T) {
+	var tokenRequests asyncCounter
+	addr, stop := newFakeServer(t, 0, func(request []byte) []byte {
+		if response := fakeTokenResponse(request); response != nil {
+			tokenRequests.Inc()
+			return response
+		}
+
+		switch {
+		case bytes.HasSuffix(request, requestInfoRaw):
+			info := ServerInfo{Name: "fake server", Map: "ctf5", MaxClients: 16}
+			data, _ := info.MarshalBinary()
+			return fakeResponse(request, sendInfoRaw, data)
+		case bytes.HasSuffix(request, requestServerCountRaw):
+			return fakeResponse(request, sendServerCountRaw, []byte{0, 42})
+		}
+		return nil
+	})
+	defer stop()
+
+	q, err := NewQuerier(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		info, err := q.ServerInfo(TimeoutServers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Name != "fake server" || info.Address != addr.String() {
+			t.Fatalf("unexpected server info: %s", info.String())
+		}
+
+		count, err := q.ServerCount(TimeoutServers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 42 {
+			t.Fatalf("expected server count 42, got %d", count)
+		}
+	}
+
+	if tokenRequests.String() != "1" {
+		t.Fatalf("expected the token to be reused, token requests: %s", tokenRequests.String())
+	}
+
+	if err = q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = q.ServerInfo(TimeoutServers); err == nil {
+		t.Fatal("expected an error after the querier has been closed")
+	}
+}
+
+func TestQuerierTimeout(t *testing.T) {
+	addr, stop := newFakeServer(t, 0, func([]byte) []byte { return nil })
+	defer stop()
+
+	q, err := NewQuerier(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	_, err = q.ServerInfo(minTimeout)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Querier.ServerInfo() error = %v, want %v", err, ErrTimeout)
+	}
+}
+
+func TestQuerierDiscardsLateResponses(t *testing.T) {
+	// the first round of every query is not answered in time, so that the responses
+	// to the requests of the following round arrive after the query.
+	const delay = 100 * time.Millisecond
+
+	addr, stop := newFakeServer(t, delay, func(request []byte) []byte {
+		if response := fakeTokenResponse(request); response != nil {
+			return response
+		}
+		if bytes.HasSuffix(request, requestInfoRaw) {
+			// the name contains the time at which the request was answered
+			info := ServerInfo{Name: strconv.FormatInt(time.Now().UnixNano(), 10), MaxClients: 16}
+			data, _ := info.MarshalBinary()
+			return fakeResponse(request, sendInfoRaw, data)
+		}
+		return nil
+	})
+	defer stop()
+
+	q, err := NewQuerier(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	for i := 0; i < 3; i++ {
+		begin := time.Now()
+		info, err := q.ServerInfo(TimeoutServers)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		answeredAt, err := strconv.ParseInt(info.Name, 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if answeredAt < begin.UnixNano() {
+			t.Fatalf("query %d returned a response to a previous query, answered %s before the query", i, begin.Sub(time.Unix(0, answeredAt)))
+		}
+
+		// wait for the late responses
+		time.Sleep(3 * delay)
+	}
+}
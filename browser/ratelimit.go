@@ -0,0 +1,136 @@
+package browser
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket that caps the number of outgoing packets per second.
+// A single RateLimiter can be shared by multiple goroutines, in which case the aggregate
+// rate of all of them is capped.
+// A nil *RateLimiter does not limit anything.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // packets per second
+	burst  float64 // max number of packets that can be sent at once
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a new rate limiter that allows packetsPerSecond packets to be sent on average
+// and at most burst packets at once. A burst below 1 is set to 1.
+// If packetsPerSecond is not positive, nil is returned, which does not limit anything.
+func NewRateLimiter(packetsPerSecond float64, burst int) *RateLimiter {
+	if packetsPerSecond <= 0 {
+		return nil
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &RateLimiter{
+		rate:   packetsPerSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until the next packet may be sent.
+func (rl *RateLimiter) Wait() {
+	rl.WaitUntil(time.Time{})
+}
+
+// WaitUntil blocks until the next packet may be sent, but not beyond the passed deadline.
+// If the packet could not be sent before the deadline, WaitUntil returns ErrTimeout immediately
+// and gives back its reservation, so that it does not delay any other caller.
+// A zero deadline means that WaitUntil waits as long as needed, like Wait.
+func (rl *RateLimiter) WaitUntil(deadline time.Time) error {
+	if rl == nil {
+		return nil
+	}
+
+	rl.mu.Lock()
+	now := time.Now()
+	rl.tokens += now.Sub(rl.last).Seconds() * rl.rate
+	if rl.tokens > rl.burst {
+		rl.tokens = rl.burst
+	}
+	rl.last = now
+
+	// reserve a token, a negative value means that the caller needs to wait
+	// until the token has been refilled.
+	rl.tokens--
+	wait := time.Duration(0)
+	if rl.tokens < 0 {
+		wait = time.Duration(-rl.tokens / rl.rate * float64(time.Second))
+	}
+
+	if !deadline.IsZero() && now.Add(wait).After(deadline) {
+		// give back the reservation
+		rl.tokens++
+		rl.mu.Unlock()
+		return ErrTimeout
+	}
+	rl.mu.Unlock()
+
+	time.Sleep(wait)
+	return nil
+}
+
+// Limit wraps the passed connection, so that every Write, e.g. by RequestToken or Request,
+// consumes a token from the rate limiter before writing to the connection.
+// Writes do not wait beyond the write deadline of the connection, instead they fail with ErrTimeout.
+// If the rate limiter is nil, the connection is returned as is.
+func (rl *RateLimiter) Limit(rwd ReadWriteDeadliner) ReadWriteDeadliner {
+	if rl == nil {
+		return rwd
+	}
+	return &rateLimitedConn{ReadWriteDeadliner: rwd, limiter: rl}
+}
+
+// rateLimitedConn waits for the rate limiter before every write
+type rateLimitedConn struct {
+	ReadWriteDeadliner
+	limiter *RateLimiter
+
+	mu            sync.Mutex
+	writeDeadline time.Time
+}
+
+func (c *rateLimitedConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	deadline := c.writeDeadline
+	c.mu.Unlock()
+
+	if err := c.limiter.WaitUntil(deadline); err != nil {
+		return 0, err
+	}
+	return c.ReadWriteDeadliner.Write(b)
+}
+
+func (c *rateLimitedConn) SetDeadline(t time.Time) error {
+	c.setWriteDeadline(t)
+	return c.ReadWriteDeadliner.SetDeadline(t)
+}
+
+func (c *rateLimitedConn) SetWriteDeadline(t time.Time) error {
+	c.setWriteDeadline(t)
+	return c.ReadWriteDeadliner.SetWriteDeadline(t)
+}
+
+func (c *rateLimitedConn) setWriteDeadline(t time.Time) {
+	c.mu.Lock()
+	c.writeDeadline = t
+	c.mu.Unlock()
+}
+
+// RemoteAddr returns the remote address of the wrapped connection, if it has one.
+func (c *rateLimitedConn) RemoteAddr() net.Addr {
+	if conn, ok := c.ReadWriteDeadliner.(interface{ RemoteAddr() net.Addr }); ok {
+		return conn.RemoteAddr()
+	}
+	return nil
+}
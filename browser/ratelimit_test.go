@@ -0,0 +1,149 @@
+package browser
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Wait(t *testing.T) {
+	var nilLimiter *RateLimiter
+	nilLimiter.Wait() // must not block or panic
+
+	if NewRateLimiter(0, 1) != nil {
+		t.Fatal("expected nil rate limiter for a non-positive rate")
+	}
+
+	const (
+		rate       = 100.0
+		goroutines = 4
+		perRoutine = 5
+	)
+
+	rl := NewRateLimiter(rate, 1)
+
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+
+	begin := time.Now()
+	for i := 0; i < goroutines; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perRoutine; j++ {
+				rl.Wait()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the first packet is sent immediately, every following one has to wait for a refill.
+	minElapsed := time.Duration(float64(goroutines*perRoutine-1) / rate * float64(time.Second))
+	if elapsed := time.Since(begin); elapsed < minElapsed {
+		t.Fatalf("aggregate rate not limited: elapsed %s, expected at least %s", elapsed, minElapsed)
+	}
+}
+
+func TestRateLimiter_Limit(t *testing.T) {
+	conn := &silentConn{}
+
+	var nilLimiter *RateLimiter
+	if nilLimiter.Limit(conn) != conn {
+		t.Fatal("expected a nil limiter to return the connection as is")
+	}
+
+	limited := NewRateLimiter(1000, 1).Limit(conn)
+	for i := 0; i < 3; i++ {
+		if err := RequestToken(limited); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if conn.Writes() != 3 {
+		t.Fatalf("expected 3 writes, got %d", conn.Writes())
+	}
+}
+
+func TestRateLimiter_WaitUntil(t *testing.T) {
+	var nilLimiter *RateLimiter
+	if err := nilLimiter.WaitUntil(time.Now()); err != nil {
+		t.Fatalf("expected a nil limiter to never time out, got %v", err)
+	}
+
+	rl := NewRateLimiter(10, 1)
+	if err := rl.WaitUntil(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the next token is refilled after 100ms
+	begin := time.Now()
+	if err := rl.WaitUntil(begin.Add(10 * time.Millisecond)); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("WaitUntil() error = %v, want %v", err, ErrTimeout)
+	}
+	if elapsed := time.Since(begin); elapsed >= 10*time.Millisecond {
+		t.Fatalf("expected WaitUntil to return immediately, took %s", elapsed)
+	}
+
+	// the reservation of the timed out call must have been given back,
+	// otherwise this call would have to wait for two refills.
+	if err := rl.WaitUntil(begin.Add(150 * time.Millisecond)); err != nil {
+		t.Fatalf("expected the timed out reservation to be given back, got %v", err)
+	}
+
+	conn := &silentConn{}
+	limited := rl.Limit(conn)
+	limited.SetWriteDeadline(time.Now())
+	if err := RequestToken(limited); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("RequestToken() error = %v, want %v", err, ErrTimeout)
+	}
+	if conn.Writes() != 0 {
+		t.Fatalf("expected no write after the write deadline, got %d", conn.Writes())
+	}
+}
+
+func TestRateLimiter_FetchTokenContention(t *testing.T) {
+	addr, stop := newFakeServer(t, 0, fakeTokenResponse)
+	defer stop()
+
+	const (
+		rate       = 20.0
+		goroutines = 30 // more than the rate allows within one second
+		timeout    = 2 * time.Second
+	)
+
+	rl := NewRateLimiter(rate, 1)
+
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+
+	errs := make(chan error, goroutines)
+	begin := time.Now()
+	for i := 0; i < goroutines; i++ {
+		go func() {
+			defer wg.Done()
+
+			conn, err := net.DialUDP("udp", nil, addr)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer conn.Close()
+
+			_, err = FetchToken(rl.Limit(conn), timeout)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("FetchToken() error = %v", err)
+		}
+	}
+
+	if elapsed := time.Since(begin); elapsed > timeout+500*time.Millisecond {
+		t.Fatalf("rate limited writes exceeded the timeout: elapsed %s, timeout %s", elapsed, timeout)
+	}
+}
@@ -0,0 +1,66 @@
+package browser
+
+import "sort"
+
+// SortKey defines by which field server infos are sorted
+type SortKey int
+
+const (
+	// SortByPlayers sorts the fullest servers first
+	SortByPlayers SortKey = iota
+	// SortByName sorts servers alphabetically by their name, servers without a name are sorted last
+	SortByName
+	// SortByMap sorts servers alphabetically by their map, servers without a map are sorted last
+	SortByMap
+)
+
+// SortServerInfos sorts the passed infos in place by the passed key.
+// Servers that are equal regarding the sort key are sorted by their name and
+// finally by their address, which makes the resulting order deterministic.
+func SortServerInfos(infos []ServerInfo, by SortKey) {
+	var less func(a, b *ServerInfo) bool
+	switch by {
+	case SortByName:
+		less = lessByName
+	case SortByMap:
+		less = lessByMap
+	default:
+		less = lessByPlayers
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return less(&infos[i], &infos[j])
+	})
+}
+
+func lessByPlayers(a, b *ServerInfo) bool {
+	if a.NumPlayers != b.NumPlayers {
+		return a.NumPlayers > b.NumPlayers
+	}
+	if a.NumClients != b.NumClients {
+		return a.NumClients > b.NumClients
+	}
+	return lessByName(a, b)
+}
+
+func lessByName(a, b *ServerInfo) bool {
+	if a.Name != b.Name {
+		return lessString(a.Name, b.Name)
+	}
+	return a.Key() < b.Key()
+}
+
+func lessByMap(a, b *ServerInfo) bool {
+	if a.Map != b.Map {
+		return lessString(a.Map, b.Map)
+	}
+	return lessByName(a, b)
+}
+
+// lessString compares two different strings, empty strings are sorted last
+func lessString(a, b string) bool {
+	if a == "" || b == "" {
+		return b == ""
+	}
+	return a < b
+}
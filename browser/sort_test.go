@@ -0,0 +1,43 @@
+package browser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSortServerInfos(t *testing.T) {
+	infos := func() []ServerInfo {
+		return []ServerInfo{
+			{Address: "127.0.0.1:8303", Name: "b", Map: "ctf5", NumPlayers: 2, NumClients: 2},
+			{Address: "127.0.0.1:8304", Name: "", Map: "dm1", NumPlayers: 4, NumClients: 4},
+			{Address: "127.0.0.1:8305", Name: "a", Map: "", NumPlayers: 2, NumClients: 3},
+			{Address: "127.0.0.1:8306", Name: "", Map: "dm1", NumPlayers: 0, NumClients: 0},
+			{Address: "127.0.0.1:8307", Name: "a", Map: "ctf5", NumPlayers: 0, NumClients: 0},
+		}
+	}
+
+	tests := []struct {
+		name string
+		by   SortKey
+		want []string
+	}{
+		{"players", SortByPlayers, []string{"127.0.0.1:8304", "127.0.0.1:8305", "127.0.0.1:8303", "127.0.0.1:8307", "127.0.0.1:8306"}},
+		{"name", SortByName, []string{"127.0.0.1:8305", "127.0.0.1:8307", "127.0.0.1:8303", "127.0.0.1:8304", "127.0.0.1:8306"}},
+		{"map", SortByMap, []string{"127.0.0.1:8307", "127.0.0.1:8303", "127.0.0.1:8304", "127.0.0.1:8306", "127.0.0.1:8305"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sorted := infos()
+			SortServerInfos(sorted, tt.by)
+
+			got := make([]string, 0, len(sorted))
+			for _, info := range sorted {
+				got = append(got, info.Address)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SortServerInfos() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -14,12 +14,24 @@ var (
 
 	// ErrNotEnoughDataToUnpack is used when the user tries to retrieve more data with NextBytes() than there is available.
 	ErrNotEnoughDataToUnpack = errors.New("you are trying to read more data than is available")
+
+	// ErrInvalidVarInt is returned if the data to unpack is truncated or malformed.
+	ErrInvalidVarInt = errors.New("invalid varint, data is truncated or malformed")
 )
 
 const (
 	// max bytes that can be received for one integer
 	maxBytesInVarInt = 5
 
+	// MaxBytesInVarInt is the max number of bytes that one integer is packed into by VarInt.
+	MaxBytesInVarInt = maxBytesInVarInt
+
+	// max bytes that can be received for one 64 bit integer
+	maxBytesInVarInt64 = 10
+
+	// max bytes that can be received for one unsigned integer
+	maxBytesInUVarInt = 5
+
 	// with how many bytes the packer is initialized
 	packerInitialSize = 2048
 )
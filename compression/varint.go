@@ -1,8 +1,9 @@
 package compression
 
 import (
+	"fmt"
+	"io"
 	"math"
-	"unsafe"
 )
 
 // VarInt is used to compress integers in a variable length format.
@@ -19,6 +20,51 @@ func NewVarIntFrom(bytes []byte) VarInt {
 	return VarInt{bytes}
 }
 
+// ZigZagEncode maps signed integers to unsigned integers, so that values with a small absolute value
+// result in small unsigned values: 0 -> 0, -1 -> 1, 1 -> 2, -2 -> 3, ...
+// The lowest bit of the result is the sign, the remaining bits are the value, which is inverted if negative.
+// This is the sign handling that is used by Pack.
+func ZigZagEncode(value int) uint {
+	return uint(ZigZagEncode64(int64(value)))
+}
+
+// ZigZagDecode is the inverse of ZigZagEncode.
+// This is the sign handling that is used by Unpack.
+func ZigZagDecode(encoded uint) int {
+	return int(ZigZagDecode64(uint64(encoded)))
+}
+
+// ZigZagEncode64 is the same as ZigZagEncode for 64 bit integers.
+// This is the sign handling that is used by Pack64.
+func ZigZagEncode64(value int64) uint64 {
+	return uint64(value<<1) ^ uint64(value>>(64-1))
+}
+
+// ZigZagDecode64 is the inverse of ZigZagEncode64.
+// This is the sign handling that is used by Unpack64.
+func ZigZagDecode64(encoded uint64) int64 {
+	return int64(encoded>>1) ^ -int64(encoded&1)
+}
+
+// PackedSize returns the number of bytes that the packed value would occupy, without packing it.
+// This allows to Grow the buffer to the exact size before packing multiple values.
+func PackedSize(value int) int {
+	return PackedSize64(int64(value))
+}
+
+// PackedSize64 returns the number of bytes that the value packed with Pack64 would occupy, without packing it.
+func PackedSize64(value int64) int {
+	magnitude := ZigZagEncode64(value) >> 1 // the sign is stored in a separate bit
+
+	size := 1
+	magnitude >>= 6 // first byte contains 6 bits
+	for magnitude != 0 {
+		size++
+		magnitude >>= 7 // every following byte contains 7 bits
+	}
+	return size
+}
+
 // Size returns the length of the data.
 // not its capacity
 func (v *VarInt) Size() int {
@@ -37,12 +83,68 @@ func (v *VarInt) Bytes() []byte {
 	return v.Compressed
 }
 
-// Clear clears the internal Compressed buffer
+// Clear clears the internal Compressed buffer by allocating a fresh buffer.
+// Use Reset in order to reuse the already allocated buffer instead.
 func (v *VarInt) Clear() {
 	v.Compressed = make([]byte, 0, maxBytesInVarInt)
 }
 
-// Grow increases size of the underlying array to fit another n elements
+// Reset empties the internal Compressed buffer, but keeps its capacity, so that it can be reused
+// for packing without reallocating.
+// The previously returned Bytes() are overwritten by subsequent calls to Pack, so they must not be used anymore.
+// Unpacking advances the buffer, which is why only the capacity of the not yet unpacked part is kept.
+func (v *VarInt) Reset() {
+	v.Compressed = v.Compressed[:0]
+}
+
+// Write implements the io.Writer interface and appends the raw bytes of p to the Compressed buffer.
+// The appended bytes are expected to be already packed values that can be unpacked afterwards.
+func (v *VarInt) Write(p []byte) (n int, err error) {
+	if v.Compressed == nil {
+		v.Clear()
+	}
+	v.Compressed = append(v.Compressed, p...)
+	return len(p), nil
+}
+
+// Read implements the io.Reader interface and drains the raw bytes from the front of the Compressed buffer,
+// the same way Unpack advances the buffer.
+// Returns io.EOF if there is no data left to be read.
+func (v *VarInt) Read(p []byte) (n int, err error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if len(v.Compressed) == 0 {
+		return 0, io.EOF
+	}
+
+	n = copy(p, v.Compressed)
+	v.Compressed = v.Compressed[n:]
+	return n, nil
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+// It returns a copy of the not yet unpacked data, so that modifying the returned data
+// does not modify the internal buffer.
+func (v *VarInt) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, len(v.Compressed))
+	copy(data, v.Compressed)
+	return data, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+// The internal buffer is replaced by a copy of the passed data.
+func (v *VarInt) UnmarshalBinary(data []byte) error {
+	v.Compressed = make([]byte, len(data), len(data)+maxBytesInVarInt)
+	copy(v.Compressed, data)
+	return nil
+}
+
+// Grow makes sure that the underlying array has enough spare capacity to fit another n bytes.
+// If there is already enough spare capacity, Grow does nothing, otherwise the array is reallocated once.
+// When packing many values, call Grow first with the total size, e.g. computed with PackedSize,
+// so that the following calls to Pack do not need to reallocate the array.
 func (v *VarInt) Grow(n int) {
 	if v.Compressed == nil {
 		if n < maxBytesInVarInt {
@@ -53,13 +155,18 @@ func (v *VarInt) Grow(n int) {
 		return
 	}
 
-	newBuffer := make([]byte, len(v.Compressed), cap(v.Compressed)+n)
+	if cap(v.Compressed)-len(v.Compressed) >= n {
+		return
+	}
+
+	newBuffer := make([]byte, len(v.Compressed), len(v.Compressed)+n)
 	copy(newBuffer, v.Compressed)
 
 	v.Compressed = newBuffer
 }
 
 // Unpack the wrapped Compressed buffer
+// Returns ErrInvalidVarInt without advancing the buffer, if the data is truncated.
 func (v *VarInt) Unpack() (value int, err error) {
 
 	if v.Compressed == nil {
@@ -75,8 +182,8 @@ func (v *VarInt) Unpack() (value int, err error) {
 	data := v.Compressed
 
 	// handle first byte (most right side)
-	sign := int((data[index] >> 6) & 0b00000001)
-	value = int(data[index] & 0b00111111)
+	sign := uint((data[index] >> 6) & 0b00000001)
+	magnitude := uint(data[index] & 0b00111111)
 
 	// handle 2nd - nth byte
 	for i := 0; i < maxBytesInVarInt-1; i++ {
@@ -84,48 +191,107 @@ func (v *VarInt) Unpack() (value int, err error) {
 			break
 		}
 		index++
-		value |= int(data[index]&0b01111111) << (6 + 7*i)
+		if index >= len(data) {
+			// extend bit is set, but the data is truncated
+			return 0, ErrInvalidVarInt
+		}
+		magnitude |= uint(data[index]&0b01111111) << (6 + 7*i)
 	}
 
 	index++
-	value ^= -sign // if(sign) value = ~(value)
+	value = ZigZagDecode(magnitude<<1 | sign) // if(sign) value = ~(value)
 
 	// continue walking over the buffer
 	v.Compressed = v.Compressed[index:]
 	return
 }
 
+// Peek unpacks the next value exactly like Unpack does, but without advancing the wrapped Compressed buffer.
+// A subsequent call to Unpack returns the same value.
+func (v *VarInt) Peek() (value int, err error) {
+	if len(v.Compressed) == 0 {
+		err = ErrNoDataToUnpack
+		return
+	}
+
+	peeker := VarInt{v.Compressed}
+	return peeker.Unpack()
+}
+
 // Pack a value to internal buffer
 func (v *VarInt) Pack(value int) {
 	if v.Compressed == nil {
 		v.Clear()
 	}
+	v.pack(value)
+}
+
+// PackSlice packs all values to the internal buffer.
+// The buffer is grown only once for all of the values.
+func (v *VarInt) PackSlice(values []int) {
+	v.Grow(len(values) * maxBytesInVarInt) // also initializes a nil buffer
+
+	for _, value := range values {
+		v.pack(value)
+	}
+}
+
+// UnpackSlice unpacks n consecutive values from the wrapped Compressed buffer.
+// If the buffer runs out of data before n values could be unpacked, the already unpacked values
+// are returned with a wrapped error.
+// A negative n unpacks nothing and returns an empty slice.
+func (v *VarInt) UnpackSlice(n int) (values []int, err error) {
+	if v.Compressed == nil {
+		v.Clear()
+	}
 
+	// every value needs at least one byte
+	size := n
+	if len(v.Compressed) < size {
+		size = len(v.Compressed)
+	}
+	if size < 0 {
+		size = 0
+	}
+	values = make([]int, 0, size)
+
+	for i := 0; i < n; i++ {
+		value, err := v.Unpack()
+		if err != nil {
+			return values, fmt.Errorf("unpacked %d of %d values: %w", i, n, err)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// pack packs a value to the internal buffer, which must not be nil
+func (v *VarInt) pack(value int) {
 	if value < math.MinInt32 || math.MaxInt32 < value {
 		panic("ERROR: value to Pack is out of bounds, should be within range [-2147483648:2147483647] (32bit)")
 	}
 
-	intSize := unsafe.Sizeof(value)
+	encoded := ZigZagEncode(value)
 
 	// buffer
-	data := make([]byte, maxBytesInVarInt) // predefined content of zeroes
+	var data [maxBytesInVarInt]byte // predefined content of zeroes
 	index := 0
 
-	data[index] = byte(value>>(intSize*8-7)) & 0b01000000 // set sign bit if i<0
-	value = value ^ (value >> (intSize*8 - 1))            // if(i<0) i = ~i
+	data[index] = byte(encoded<<6) & 0b01000000 // set sign bit if i<0
+	encoded >>= 1                               // discard the sign bit, keep the magnitude
 
-	data[index] |= byte(value) & 0b00111111 // pack 6bit into data
-	value >>= 6                             // discard 6 bits
+	data[index] |= byte(encoded) & 0b00111111 // pack 6bit into data
+	encoded >>= 6                             // discard 6 bits
 
-	if value != 0 {
+	if encoded != 0 {
 		data[index] |= 0b10000000 // set extend bit
 
 		for {
 			index++
-			data[index] = byte(value) & 0b01111111 //  pack 7 bits
-			value >>= 7                            // discard 7 bits
+			data[index] = byte(encoded) & 0b01111111 //  pack 7 bits
+			encoded >>= 7                            // discard 7 bits
 
-			if value != 0 {
+			if encoded != 0 {
 				data[index] |= 1 << 7 // set extend bit
 			} else {
 				break // break if value is 0
@@ -134,6 +300,158 @@ func (v *VarInt) Pack(value int) {
 		}
 	}
 
+	index++
+	v.Compressed = append(v.Compressed, data[:index]...) // ignore unused 'space'
+}
+
+// Unpack64 unpacks a 64 bit integer from the wrapped Compressed buffer.
+// The format is the same as the one used by Unpack, but up to 10 bytes are used.
+func (v *VarInt) Unpack64() (value int64, err error) {
+
+	if v.Compressed == nil {
+		v.Clear()
+	}
+
+	if len(v.Compressed) == 0 {
+		err = ErrNoDataToUnpack
+		return
+	}
+
+	index := 0
+	data := v.Compressed
+
+	// handle first byte (most right side)
+	sign := uint64((data[index] >> 6) & 0b00000001)
+	magnitude := uint64(data[index] & 0b00111111)
+
+	// handle 2nd - nth byte
+	for i := 0; i < maxBytesInVarInt64-1; i++ {
+		if data[index] < 0b10000000 {
+			break
+		}
+		index++
+		if index >= len(data) {
+			// extend bit is set, but the data is truncated
+			return 0, ErrInvalidVarInt
+		}
+		magnitude |= uint64(data[index]&0b01111111) << (6 + 7*i)
+	}
+
+	index++
+	value = ZigZagDecode64(magnitude<<1 | sign) // if(sign) value = ~(value)
+
+	// continue walking over the buffer
+	v.Compressed = v.Compressed[index:]
+	return
+}
+
+// Pack64 packs a 64 bit value to the internal buffer.
+// Values within the 32 bit range are packed exactly like Pack does,
+// bigger values use up to 10 bytes.
+func (v *VarInt) Pack64(value int64) {
+	if v.Compressed == nil {
+		v.Clear()
+	}
+
+	// buffer
+	data := make([]byte, maxBytesInVarInt64) // predefined content of zeroes
+	index := 0
+
+	encoded := ZigZagEncode64(value)
+
+	data[index] = byte(encoded<<6) & 0b01000000 // set sign bit if i<0
+	encoded >>= 1                               // discard the sign bit, keep the magnitude
+
+	data[index] |= byte(encoded) & 0b00111111 // pack 6bit into data
+	encoded >>= 6                             // discard 6 bits
+
+	if encoded != 0 {
+		data[index] |= 0b10000000 // set extend bit
+
+		for {
+			index++
+			data[index] = byte(encoded) & 0b01111111 //  pack 7 bits
+			encoded >>= 7                            // discard 7 bits
+
+			if encoded != 0 {
+				data[index] |= 1 << 7 // set extend bit
+			} else {
+				break // break if value is 0
+			}
+
+		}
+	}
+
+	index++
+	data = data[:index] // ignore unused 'space'
+	v.Compressed = append(v.Compressed, data...)
+}
+
+// UnpackUint unpacks an unsigned integer that was packed with PackUint.
+// WARNING: The unsigned format is NOT wire compatible with the signed format of Pack and Unpack.
+// Values packed with PackUint must only be unpacked with UnpackUint.
+func (v *VarInt) UnpackUint() (value uint32, err error) {
+
+	if v.Compressed == nil {
+		v.Clear()
+	}
+
+	if len(v.Compressed) == 0 {
+		err = ErrNoDataToUnpack
+		return
+	}
+
+	index := 0
+	data := v.Compressed
+
+	// handle first byte (most right side)
+	value = uint32(data[index] & 0b01111111)
+
+	// handle 2nd - nth byte
+	for i := 0; i < maxBytesInUVarInt-1; i++ {
+		if data[index] < 0b10000000 {
+			break
+		}
+		index++
+		if index >= len(data) {
+			// extend bit is set, but the data is truncated
+			return 0, ErrInvalidVarInt
+		}
+		value |= uint32(data[index]&0b01111111) << (7 + 7*i)
+	}
+
+	index++
+
+	// continue walking over the buffer
+	v.Compressed = v.Compressed[index:]
+	return
+}
+
+// PackUint packs an unsigned value to the internal buffer.
+// Format: EDDDDDDD EDDDDDDD EDD... Extended, Data
+// There is no sign bit, which is why the whole 32 bit unsigned range fits into 5 bytes.
+// WARNING: The unsigned format is NOT wire compatible with the signed format of Pack and Unpack.
+// Do not mix both formats on the same stream.
+func (v *VarInt) PackUint(value uint32) {
+	if v.Compressed == nil {
+		v.Clear()
+	}
+
+	// buffer
+	data := make([]byte, maxBytesInUVarInt) // predefined content of zeroes
+	index := 0
+
+	for {
+		data[index] = byte(value) & 0b01111111 //  pack 7 bits
+		value >>= 7                            // discard 7 bits
+
+		if value == 0 {
+			break // break if value is 0
+		}
+		data[index] |= 0b10000000 // set extend bit
+		index++
+	}
+
 	index++
 	data = data[:index] // ignore unused 'space'
 	v.Compressed = append(v.Compressed, data...)
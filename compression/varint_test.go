@@ -1,7 +1,11 @@
 package compression
 
 import (
+	"bytes"
+	"encoding/gob"
+	"errors"
 	"fmt"
+	"io"
 	"math"
 	"math/bits"
 	"math/rand"
@@ -247,6 +251,9 @@ func TestVarInt_Grow(t *testing.T) {
 		{fmt.Sprintf("default constructed grow < %d ", maxBytesInVarInt), fields{nil}, args{0}, maxBytesInVarInt},
 		{fmt.Sprintf("default constructed grow > %d ", maxBytesInVarInt), fields{nil}, args{maxBytesInVarInt + 1}, maxBytesInVarInt + 1},
 		{"grow after already containing data", fields{make([]byte, maxBytesInVarInt)}, args{33}, 38},
+		{"enough spare capacity", fields{make([]byte, 2, 10)}, args{8}, 10},
+		{"not enough spare capacity", fields{make([]byte, 2, 10)}, args{9}, 11},
+		{"grow by zero", fields{make([]byte, 2, 2)}, args{0}, 2},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -260,3 +267,500 @@ func TestVarInt_Grow(t *testing.T) {
 		})
 	}
 }
+
+func TestPack64(t *testing.T) {
+	tests := []struct {
+		name  string
+		value int64
+		size  int
+	}{
+		{"zero", 0, 1},
+		{"minus one", -1, 1},
+		{"max int32", math.MaxInt32, 5},
+		{"min int32", math.MinInt32, 5},
+		{"max int32 + 1", math.MaxInt32 + 1, 5},
+		{"2^(6+7*4)", 1 << 34, 6},
+		{"2^(6+7*8)", 1 << 62, 10},
+		{"max int64", math.MaxInt64, 10},
+		{"min int64", math.MinInt64, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v VarInt
+			v.Pack64(tt.value)
+
+			if v.Size() != tt.size {
+				t.Errorf("Expected size: %d actual size: %d", tt.size, v.Size())
+			}
+
+			value, err := v.Unpack64()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != tt.value {
+				t.Errorf("Packed %d, Unpacked to %d", tt.value, value)
+			}
+		})
+	}
+}
+
+func TestPack64CompatibleWithPack(t *testing.T) {
+	seedSource := rand.NewSource(time.Now().UnixNano())
+	randomNumberGenerator := rand.New(seedSource)
+
+	values := []int{0, -1, 1, 0x3f, 0x40, math.MaxInt32, math.MinInt32}
+	for i := 0; i < 1000; i++ {
+		values = append(values, int(randomNumberGenerator.Int31())-int(randomNumberGenerator.Int31()))
+	}
+
+	for _, value := range values {
+		var v32, v64 VarInt
+		v32.Pack(value)
+		v64.Pack64(int64(value))
+
+		if !reflect.DeepEqual(v32.Bytes(), v64.Bytes()) {
+			t.Fatalf("Pack(%d) = %v, Pack64(%d) = %v", value, v32.Bytes(), value, v64.Bytes())
+		}
+
+		unpacked, err := v64.Unpack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unpacked != value {
+			t.Fatalf("Pack64(%d), Unpacked to %d", value, unpacked)
+		}
+	}
+}
+
+func TestPackUint(t *testing.T) {
+	tests := []struct {
+		name  string
+		value uint32
+		size  int
+	}{
+		{"zero", 0, 1},
+		{"2^7 - 1", 1<<7 - 1, 1},
+		{"2^7", 1 << 7, 2},
+		{"2^14 - 1", 1<<14 - 1, 2},
+		{"2^14", 1 << 14, 3},
+		{"2^28 - 1", 1<<28 - 1, 4},
+		{"2^28", 1 << 28, 5},
+		{"max int32 + 1", math.MaxInt32 + 1, 5},
+		{"max uint32", math.MaxUint32, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v VarInt
+			v.PackUint(tt.value)
+
+			if v.Size() != tt.size {
+				t.Errorf("Expected size: %d actual size: %d", tt.size, v.Size())
+			}
+
+			value, err := v.UnpackUint()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != tt.value {
+				t.Errorf("Packed %d, Unpacked to %d", tt.value, value)
+			}
+			if v.Size() != 0 {
+				t.Errorf("Expected all data to be consumed, %d bytes left", v.Size())
+			}
+		})
+	}
+
+	var v VarInt
+	if _, err := v.UnpackUint(); err != ErrNoDataToUnpack {
+		t.Errorf("VarInt.UnpackUint() error = %v, want %v", err, ErrNoDataToUnpack)
+	}
+}
+
+func TestVarInt_ReadWrite(t *testing.T) {
+	values := []int{0, -1, 63, 64, -65, math.MaxInt32, math.MinInt32}
+
+	var packed VarInt
+	for _, value := range values {
+		packed.Pack(value)
+	}
+	raw := append([]byte(nil), packed.Bytes()...)
+
+	// write a stream into the buffer
+	var v VarInt
+	n, err := io.Copy(&v, bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(n) != len(raw) {
+		t.Fatalf("expected %d bytes to be written, got %d", len(raw), n)
+	}
+
+	// read the stream from the buffer
+	var buf bytes.Buffer
+	n, err = io.Copy(&buf, &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), raw) {
+		t.Fatalf("expected %v got %v", raw, buf.Bytes())
+	}
+	if v.Size() != 0 {
+		t.Fatalf("expected buffer to be drained, %d bytes left", v.Size())
+	}
+
+	if _, err = v.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("VarInt.Read() error = %v, want %v", err, io.EOF)
+	}
+
+	// unpack what has been written
+	v.Write(buf.Bytes())
+	for _, expected := range values {
+		value, err := v.Unpack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != expected {
+			t.Fatalf("expected %d got %d", expected, value)
+		}
+	}
+}
+
+func TestVarInt_PackSlice(t *testing.T) {
+	values := []int{0, -1, 63, 64, -65, 1048576, math.MaxInt32, math.MinInt32}
+
+	var v VarInt
+	v.PackSlice(values)
+
+	var expected VarInt
+	for _, value := range values {
+		expected.Pack(value)
+	}
+
+	if !reflect.DeepEqual(v.Bytes(), expected.Bytes()) {
+		t.Fatalf("PackSlice() = %v, want %v", v.Bytes(), expected.Bytes())
+	}
+
+	unpacked, err := v.UnpackSlice(len(values))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(unpacked, values) {
+		t.Fatalf("UnpackSlice() = %v, want %v", unpacked, values)
+	}
+
+	v.PackSlice(values[:2])
+	unpacked, err = v.UnpackSlice(3)
+	if !errors.Is(err, ErrNoDataToUnpack) {
+		t.Fatalf("UnpackSlice() error = %v, want %v", err, ErrNoDataToUnpack)
+	}
+	if !reflect.DeepEqual(unpacked, values[:2]) {
+		t.Fatalf("UnpackSlice() = %v, want %v", unpacked, values[:2])
+	}
+
+	v.PackSlice(values)
+	unpacked, err = v.UnpackSlice(-1)
+	if err != nil || len(unpacked) != 0 {
+		t.Fatalf("UnpackSlice(-1) = %v, %v, want an empty slice", unpacked, err)
+	}
+	if v.Size() != len(expected.Bytes()) {
+		t.Fatalf("UnpackSlice(-1) consumed data, %d bytes left, want %d", v.Size(), len(expected.Bytes()))
+	}
+}
+
+func benchmarkValues(n int) []int {
+	seedSource := rand.NewSource(42)
+	randomNumberGenerator := rand.New(seedSource)
+
+	values := make([]int, n)
+	for idx := range values {
+		values[idx] = int(randomNumberGenerator.Int31()) - int(randomNumberGenerator.Int31())
+	}
+	return values
+}
+
+func BenchmarkVarInt_Pack(b *testing.B) {
+	values := benchmarkValues(1024)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var v VarInt
+		for _, value := range values {
+			v.Pack(value)
+		}
+	}
+}
+
+func BenchmarkVarInt_PackSlice(b *testing.B) {
+	values := benchmarkValues(1024)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var v VarInt
+		v.PackSlice(values)
+	}
+}
+
+func TestVarInt_Peek(t *testing.T) {
+	var v VarInt
+	if _, err := v.Peek(); err != ErrNoDataToUnpack {
+		t.Fatalf("VarInt.Peek() error = %v, want %v", err, ErrNoDataToUnpack)
+	}
+	if v.Compressed != nil {
+		t.Fatal("VarInt.Peek() mutated the empty buffer")
+	}
+
+	values := []int{-65, 1048576, 0}
+	v.PackSlice(values)
+
+	for _, expected := range values {
+		size := v.Size()
+
+		peeked, err := v.Peek()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.Size() != size {
+			t.Fatalf("VarInt.Peek() consumed data: size before %d after %d", size, v.Size())
+		}
+
+		unpacked, err := v.Unpack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if peeked != expected || unpacked != expected {
+			t.Fatalf("expected %d, peeked %d, unpacked %d", expected, peeked, unpacked)
+		}
+	}
+}
+
+func TestVarInt_Reset(t *testing.T) {
+	var v VarInt
+	v.Grow(64)
+	capacity := cap(v.Compressed)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		v.Pack(math.MaxInt32)
+		v.Pack(math.MinInt32)
+		v.Reset()
+	})
+
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %f", allocs)
+	}
+	if v.Size() != 0 {
+		t.Errorf("expected size 0, got %d", v.Size())
+	}
+	if cap(v.Compressed) != capacity {
+		t.Errorf("expected capacity %d, got %d", capacity, cap(v.Compressed))
+	}
+}
+
+func TestVarInt_UnpackTruncated(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"single extend byte", []byte{0x80}},
+		{"extend and sign", []byte{0b11000000}},
+		{"two extend bytes", []byte{0x80, 0x80}},
+		{"four extend bytes", []byte{0xff, 0xff, 0xff, 0xff}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewVarIntFrom(tt.data)
+			if _, err := v.Unpack(); err != ErrInvalidVarInt {
+				t.Errorf("VarInt.Unpack() error = %v, want %v", err, ErrInvalidVarInt)
+			}
+			if v.Size() != len(tt.data) {
+				t.Errorf("VarInt.Unpack() advanced the buffer on error")
+			}
+
+			if _, err := v.Unpack64(); err != ErrInvalidVarInt {
+				t.Errorf("VarInt.Unpack64() error = %v, want %v", err, ErrInvalidVarInt)
+			}
+
+			if _, err := v.UnpackUint(); err != ErrInvalidVarInt {
+				t.Errorf("VarInt.UnpackUint() error = %v, want %v", err, ErrInvalidVarInt)
+			}
+		})
+	}
+}
+
+func TestPackedSize(t *testing.T) {
+	tests := []struct {
+		name  string
+		value int64
+		want  int
+	}{
+		{"zero", 0, 1},
+		{"2^6 - 1", 1<<6 - 1, 1},
+		{"-2^6", -(1 << 6), 1},
+		{"2^6", 1 << 6, 2},
+		{"-2^6 - 1", -(1 << 6) - 1, 2},
+		{"2^13 - 1", 1<<13 - 1, 2},
+		{"2^13", 1 << 13, 3},
+		{"2^20 - 1", 1<<20 - 1, 3},
+		{"2^20", 1 << 20, 4},
+		{"2^27 - 1", 1<<27 - 1, 4},
+		{"2^27", 1 << 27, 5},
+		{"max int32", math.MaxInt32, 5},
+		{"min int32", math.MinInt32, 5},
+		{"2^34", 1 << 34, 6},
+		{"max int64", math.MaxInt64, 10},
+		{"min int64", math.MinInt64, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v VarInt
+			v.Pack64(tt.value)
+			if v.Size() != tt.want {
+				t.Fatalf("invalid test case: packed size %d, want %d", v.Size(), tt.want)
+			}
+
+			if got := PackedSize64(tt.value); got != tt.want {
+				t.Errorf("PackedSize64() = %v, want %v", got, tt.want)
+			}
+
+			if math.MinInt32 <= tt.value && tt.value <= math.MaxInt32 {
+				if got := PackedSize(int(tt.value)); got != tt.want {
+					t.Errorf("PackedSize() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestZigZag(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   int
+		encoded uint
+	}{
+		{"zero", 0, 0},
+		{"minus one", -1, 1},
+		{"one", 1, 2},
+		{"minus two", -2, 3},
+		{"two", 2, 4},
+		{"max int32", math.MaxInt32, math.MaxUint32 - 1},
+		{"min int32", math.MinInt32, math.MaxUint32},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ZigZagEncode(tt.value); got != tt.encoded {
+				t.Errorf("ZigZagEncode() = %v, want %v", got, tt.encoded)
+			}
+			if got := ZigZagDecode(tt.encoded); got != tt.value {
+				t.Errorf("ZigZagDecode() = %v, want %v", got, tt.value)
+			}
+		})
+	}
+
+	for _, value := range []int{math.MinInt32 + 1, math.MaxInt32 - 1, -123456789, 123456789} {
+		if got := ZigZagDecode(ZigZagEncode(value)); got != value {
+			t.Errorf("ZigZagDecode(ZigZagEncode(%d)) = %d", value, got)
+		}
+	}
+
+	tests64 := []struct {
+		value   int64
+		encoded uint64
+	}{
+		{0, 0},
+		{-1, 1},
+		{1, 2},
+		{math.MaxInt64, math.MaxUint64 - 1},
+		{math.MinInt64, math.MaxUint64},
+	}
+	for _, tt := range tests64 {
+		if got := ZigZagEncode64(tt.value); got != tt.encoded {
+			t.Errorf("ZigZagEncode64(%d) = %v, want %v", tt.value, got, tt.encoded)
+		}
+		if got := ZigZagDecode64(tt.encoded); got != tt.value {
+			t.Errorf("ZigZagDecode64(%d) = %v, want %v", tt.encoded, got, tt.value)
+		}
+	}
+}
+
+func TestVarInt_MarshalBinary(t *testing.T) {
+	values := []int{0, -1, 64, math.MaxInt32, math.MinInt32}
+
+	var v VarInt
+	v.PackSlice(values)
+
+	data, err := v.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, v.Bytes()) {
+		t.Fatalf("VarInt.MarshalBinary() = %v, want %v", data, v.Bytes())
+	}
+
+	// modifying the returned data must not modify the buffer
+	data[0] = 0xff
+	if v.Bytes()[0] == 0xff {
+		t.Fatal("VarInt.MarshalBinary() returned the internal buffer")
+	}
+
+	// round trip through gob, which uses the encoding.BinaryMarshaler interface
+	var buf bytes.Buffer
+	if err = gob.NewEncoder(&buf).Encode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded VarInt
+	if err = gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	unpacked, err := decoded.UnpackSlice(len(values))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(unpacked, values) {
+		t.Fatalf("expected %v got %v", values, unpacked)
+	}
+
+	// the unmarshaled buffer must not share the passed data
+	raw := []byte{0b00100000}
+	var other VarInt
+	if err = other.UnmarshalBinary(raw); err != nil {
+		t.Fatal(err)
+	}
+	raw[0] = 0
+	if value, _ := other.Unpack(); value != 32 {
+		t.Fatalf("expected 32 got %d", value)
+	}
+}
+
+func TestVarInt_GrowKeepsBuffer(t *testing.T) {
+	v := NewVarIntFrom(make([]byte, 0, 16))
+	v.Pack(1)
+	before := &v.Bytes()[0]
+
+	v.Grow(8)
+	if &v.Bytes()[0] != before {
+		t.Fatal("VarInt.Grow() reallocated, even though there was enough spare capacity")
+	}
+}
+
+func BenchmarkVarInt_GrowAndPack(b *testing.B) {
+	values := benchmarkValues(1024)
+
+	size := 0
+	for _, value := range values {
+		size += PackedSize(value)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var v VarInt
+		v.Grow(size)
+		for _, value := range values {
+			v.Pack(value)
+		}
+	}
+}